@@ -0,0 +1,123 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+
+	"rescribe.xyz/integral"
+)
+
+const (
+	defaultK = 0.5
+	minAutoK = 0.05
+	maxAutoK = 0.8
+	// number of standard deviations below a background window's
+	// mean which the threshold should be, to avoid noise
+	bgNoiseDevs = 2.5
+)
+
+// EstimateK estimates a good k value to use with Sauvola's algorithm
+// for an image, based on the contrast between the text strokes and
+// the background, and the amount of variation in the background.
+func EstimateK(img image.Image, windowsize int) float64 {
+	b := img.Bounds()
+
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, img, b.Min, draw.Src)
+	intSqImg := integral.NewSqImage(b)
+	draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+
+	return PreCalcedEstimateK(*intImg, *intSqImg, img, windowsize)
+}
+
+// PreCalcedEstimateK implements EstimateK using precalculated
+// Integral Images.
+//
+// Windows across the image are sorted into text and background
+// using the global Otsu threshold. For text windows, k is chosen
+// so that the threshold falls midway between the typical stroke
+// and background shades. For background windows, k is chosen so
+// that the threshold falls far enough below the mean to leave
+// paper texture white. The larger of the two is returned.
+func PreCalcedEstimateK(intImg integral.Image, intSqImg integral.SqImage, img image.Image, windowsize int) float64 {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+
+	t := OtsuThreshold(gray)
+	hist := histogram(gray)
+	var fgsum, fgnum, bgsum, bgnum float64
+	for i, n := range hist {
+		if i < int(t) {
+			fgsum += float64(i * n)
+			fgnum += float64(n)
+		} else {
+			bgsum += float64(i * n)
+			bgnum += float64(n)
+		}
+	}
+	if fgnum == 0 || bgnum == 0 {
+		return defaultK
+	}
+	target := (fgsum/fgnum + bgsum/bgnum) / 2
+
+	otsu := Otsu(gray)
+	intOtsu := integral.NewImage(b)
+	draw.Draw(intOtsu, b, otsu, b.Min, draw.Src)
+
+	step := windowsize
+	if step < 1 {
+		step = 1
+	}
+
+	var textks, bgks []float64
+	for y := b.Min.Y + step/2; y < b.Max.Y; y += step {
+		for x := b.Min.X + step/2; x < b.Max.X; x += step {
+			r := centeredRectangle(x, y, windowsize).Intersect(b)
+			area := r.Dx() * r.Dy()
+			if area == 0 {
+				continue
+			}
+			// 1 << 16 - 1 as we're using Gray16, so 1 << 16 - 1 = white
+			numdark := area - int(intOtsu.Sum(r)/(1<<16-1))
+			m, dev := integralMeanStdDev(intImg, intSqImg, r)
+			if m == 0 || dev >= 128 {
+				continue
+			}
+			switch {
+			case numdark == 0:
+				bgks = append(bgks, bgNoiseDevs*dev/(m*(1-dev/128)))
+			case numdark*20 > area && numdark*2 < area:
+				textks = append(textks, (1-target/m)/(1-dev/128))
+			}
+		}
+	}
+
+	if len(textks) == 0 {
+		return defaultK
+	}
+
+	k := percentile(textks, 50)
+	if len(bgks) > 0 {
+		k = math.Max(k, percentile(bgks, 90))
+	}
+
+	return math.Max(minAutoK, math.Min(maxAutoK, k))
+}
+
+// percentile returns the value at the given percentile of a
+// slice, sorting the slice in the process
+func percentile(s []float64, p int) float64 {
+	sort.Float64s(s)
+	i := len(s) * p / 100
+	if i >= len(s) {
+		i = len(s) - 1
+	}
+	return s[i]
+}
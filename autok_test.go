@@ -0,0 +1,44 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"fmt"
+	"image"
+	"testing"
+)
+
+func TestEstimateK(t *testing.T) {
+	cases := []struct {
+		filename string
+		wsize    int
+		min      float64
+		max      float64
+	}{
+		{"testdata/pg1.png", 41, 0.1, 0.4},
+		{"testdata/0002.png", 31, 0.4, 0.8},
+		{"testdata/1727_GREENE_0048.png", 31, 0.4, 0.8},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%d", c.filename, c.wsize), func(t *testing.T) {
+			img, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			k := EstimateK(img, c.wsize)
+			if k < c.min || k > c.max {
+				t.Errorf("Estimated k %0.2f is outside of the expected range %0.2f - %0.2f\n", k, c.min, c.max)
+			}
+		})
+	}
+
+	t.Run("blank", func(t *testing.T) {
+		blank := image.NewGray(image.Rect(0, 0, 100, 100))
+		if k := EstimateK(blank, 19); k != defaultK {
+			t.Errorf("Estimated k for blank image is %0.2f, not %0.2f\n", k, defaultK)
+		}
+	})
+}
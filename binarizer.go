@@ -0,0 +1,126 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+
+	"rescribe.xyz/integral"
+)
+
+// Binarizer is a method of converting an image to black and white.
+type Binarizer interface {
+	Binarize(img image.Image) *image.Gray
+}
+
+// IntegralBinarizer is a Binarizer which can also work from
+// precalculated Integral Images, which saves recalculating them
+// when binarizing the same image several times.
+type IntegralBinarizer interface {
+	Binarizer
+	BinarizeIntegral(intImg integral.Image, intSqImg integral.SqImage, img image.Image) *image.Gray
+}
+
+// KEstimator is a Binarizer which can estimate a good k value to
+// use for an image, for use with NewAutoKBinarizer.
+type KEstimator interface {
+	Binarizer
+	EstimateK(img image.Image) float64
+}
+
+// KDefaulter is a Binarizer which has a k value that works well
+// for most images, for use with DefaultK.
+type KDefaulter interface {
+	Binarizer
+	DefaultK() float64
+}
+
+// BinarizerParams contains the parameters which are passed to a
+// BinarizerFunc to create a Binarizer. Binarizers are free to
+// ignore any parameters they have no use for.
+type BinarizerParams struct {
+	K     float64 // K value, which controls the overall threshold level
+	Wsize int     // Window size, for local thresholding algorithms
+}
+
+// BinarizerFunc creates a Binarizer with the given parameters.
+type BinarizerFunc func(p BinarizerParams) Binarizer
+
+var (
+	binarizersMu sync.RWMutex
+	binarizers   = make(map[string]BinarizerFunc)
+)
+
+// RegisterBinarizer makes a Binarizer available by name to
+// NewBinarizer. It is typically called in an init function.
+// Registering a name a second time replaces the previous entry.
+func RegisterBinarizer(name string, f BinarizerFunc) {
+	binarizersMu.Lock()
+	defer binarizersMu.Unlock()
+	binarizers[name] = f
+}
+
+// NewBinarizer returns the Binarizer registered with name, set
+// up with the parameters in p.
+func NewBinarizer(name string, p BinarizerParams) (Binarizer, error) {
+	binarizersMu.RLock()
+	f, ok := binarizers[name]
+	binarizersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No binarizer named %s", name)
+	}
+	return f(p), nil
+}
+
+// NewAutoKBinarizer returns the Binarizer registered with name, set
+// up with the window size wsize and a k value estimated for img by
+// the Binarizer. It returns an error if the Binarizer isn't a
+// KEstimator, as k means something different to each algorithm, so
+// it can only be estimated by the algorithm itself.
+func NewAutoKBinarizer(name string, wsize int, img image.Image) (Binarizer, float64, error) {
+	bin, err := NewBinarizer(name, BinarizerParams{Wsize: wsize})
+	if err != nil {
+		return nil, 0, err
+	}
+	ke, ok := bin.(KEstimator)
+	if !ok {
+		return nil, 0, fmt.Errorf("Binarizer %s can not estimate k", name)
+	}
+	k := ke.EstimateK(img)
+	bin, err = NewBinarizer(name, BinarizerParams{K: k, Wsize: wsize})
+	return bin, k, err
+}
+
+// DefaultK returns a k value which works well for most images with
+// the Binarizer registered with name, as k means something
+// different to each algorithm. Binarizers which don't use k, which
+// aren't KDefaulters, give 0.
+func DefaultK(name string) (float64, error) {
+	bin, err := NewBinarizer(name, BinarizerParams{})
+	if err != nil {
+		return 0, err
+	}
+	kd, ok := bin.(KDefaulter)
+	if !ok {
+		return 0, nil
+	}
+	return kd.DefaultK(), nil
+}
+
+// Binarizers returns the names of all registered Binarizers,
+// sorted alphabetically.
+func Binarizers() []string {
+	binarizersMu.RLock()
+	defer binarizersMu.RUnlock()
+	var names []string
+	for n := range binarizers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"testing"
+)
+
+func TestNewBinarizer(t *testing.T) {
+	orig, err := decode("testdata/pg1.png")
+	if err != nil {
+		t.Fatalf("Could not open file testdata/pg1.png: %v\n", err)
+	}
+
+	bin, err := NewBinarizer("sauvola", BinarizerParams{K: 0.5, Wsize: 19})
+	if err != nil {
+		t.Fatalf("Could not create sauvola binarizer: %v\n", err)
+	}
+	if !imgsequal(bin.Binarize(orig), IntegralSauvola(orig, 0.5, 19)) {
+		t.Errorf("sauvola binarizer differs to IntegralSauvola\n")
+	}
+
+	_, err = NewBinarizer("nonexistent", BinarizerParams{})
+	if err == nil {
+		t.Errorf("No error returned for nonexistent binarizer\n")
+	}
+}
+
+func TestNewAutoKBinarizer(t *testing.T) {
+	orig, err := decode("testdata/pg1.png")
+	if err != nil {
+		t.Fatalf("Could not open file testdata/pg1.png: %v\n", err)
+	}
+
+	bin, k, err := NewAutoKBinarizer("sauvola", 41, orig)
+	if err != nil {
+		t.Fatalf("Could not create sauvola binarizer with estimated k: %v\n", err)
+	}
+	if expected := EstimateK(orig, 41); k != expected {
+		t.Errorf("Estimated k %0.2f differs to EstimateK %0.2f\n", k, expected)
+	}
+	if !imgsequal(bin.Binarize(orig), IntegralSauvola(orig, k, 41)) {
+		t.Errorf("sauvola binarizer with estimated k differs to IntegralSauvola\n")
+	}
+
+	_, _, err = NewAutoKBinarizer("niblack", 41, orig)
+	if err == nil {
+		t.Errorf("No error returned for niblack, which can not estimate k\n")
+	}
+}
+
+func TestDefaultK(t *testing.T) {
+	cases := []struct {
+		name string
+		k    float64
+	}{
+		{"sauvola", 0.5},
+		{"otsusauvola", 0.5},
+		{"wolf", 0.5},
+		{"niblack", -0.2},
+		{"otsu", 0},
+	}
+	for _, c := range cases {
+		k, err := DefaultK(c.name)
+		if err != nil {
+			t.Errorf("Error getting default k for %s: %v\n", c.name, err)
+		}
+		if k != c.k {
+			t.Errorf("Default k for %s is %0.2f, expected %0.2f\n", c.name, k, c.k)
+		}
+	}
+
+	if _, err := DefaultK("nonexistent"); err == nil {
+		t.Errorf("No error returned for unknown binarizer\n")
+	}
+}
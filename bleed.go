@@ -0,0 +1,154 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+)
+
+// maxMisregistration is the furthest in pixels that the text on a
+// verso image given to SuppressBleedVerso is expected to be from
+// where it shows through on the recto
+const maxMisregistration = 2
+
+// otsuThresholds3 splits the values of a histogram into three
+// classes with the multilevel version of Otsu's method, which
+// maximises the variance between the classes. It returns the two
+// thresholds, so that the classes are the values below t1, those
+// from t1 to below t2, and those from t2 up.
+func otsuThresholds3(hist [256]int) (uint8, uint8) {
+	// cumulative counts and sums of the values below each index
+	var n, sum [257]float64
+	for i, c := range hist {
+		n[i+1] = n[i] + float64(c)
+		sum[i+1] = sum[i] + float64(i*c)
+	}
+	// score returns the part of the between class variance
+	// contributed by the class of values from a to below b
+	score := func(a, b int) float64 {
+		w := n[b] - n[a]
+		if w == 0 {
+			return 0
+		}
+		s := sum[b] - sum[a]
+		return s * s / w
+	}
+
+	var best float64
+	t1, t2 := 0, 0
+	for i := 1; i < 255; i++ {
+		for j := i + 1; j < 256; j++ {
+			v := score(0, i) + score(i, j) + score(j, 256)
+			if v > best {
+				best, t1, t2 = v, i, j
+			}
+		}
+	}
+
+	return uint8(t1), uint8(t2)
+}
+
+// SuppressBleed reduces bleed-through and show-through, the faint
+// text from the other side of thin paper, in a greyscale image, so
+// that it isn't picked up by binarization. It returns a greyscale
+// image with the bleed-through turned white.
+//
+// The pixels are split into three classes by intensity with a
+// multilevel Otsu threshold: dark ink, faint marks and paper. Real
+// strokes have dark cores with strong contrast against the paper,
+// fading through the faint class only at their edges, whereas
+// bleed-through is faint throughout. So hysteresis is used: faint
+// pixels are kept only if they are connected through other faint
+// pixels to dark ink, and the rest are made white.
+func SuppressBleed(img image.Image) *image.Gray {
+	b := img.Bounds()
+	new := image.NewGray(b)
+	draw.Draw(new, b, img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	t1, t2 := otsuThresholds3(histogram(new))
+
+	keep := make([]bool, w*h)
+	var stack []int
+	for y := 0; y < h; y++ {
+		i := new.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x, i = x+1, i+1 {
+			if new.Pix[i] < t1 {
+				keep[y*w+x] = true
+				stack = append(stack, y*w+x)
+			}
+		}
+	}
+
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		px, py := p%w, p/w
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				x, y := px+dx, py+dy
+				if x < 0 || x >= w || y < 0 || y >= h || keep[y*w+x] {
+					continue
+				}
+				if new.Pix[new.PixOffset(b.Min.X+x, b.Min.Y+y)] < t2 {
+					keep[y*w+x] = true
+					stack = append(stack, y*w+x)
+				}
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		i := new.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x, i = x+1, i+1 {
+			if new.Pix[i] < t2 && !keep[y*w+x] {
+				new.Pix[i] = 255
+			}
+		}
+	}
+
+	return new
+}
+
+// SuppressBleedVerso reduces bleed-through in a greyscale image of
+// one side of a page using an image of the other side, which must
+// be mirrored and aligned so that its text is in the same place as
+// where it shows through, to within maxMisregistration pixels.
+//
+// The text of the verso is found with Otsu's method, and wherever it
+// is, any pixel of the recto which is not dark ink, as found by the
+// darkest class of a multilevel Otsu threshold, is made white. This
+// can remove bleed-through which is as dark as the faint edges of
+// real strokes, which SuppressBleed has to keep.
+//
+// An error is returned if the two images are not the same size.
+func SuppressBleedVerso(img image.Image, verso image.Image) (*image.Gray, error) {
+	b := img.Bounds()
+	vb := verso.Bounds()
+	if b.Dx() != vb.Dx() || b.Dy() != vb.Dy() {
+		return nil, errors.New("img and verso images need to be the same dimensions")
+	}
+	new := image.NewGray(b)
+	draw.Draw(new, b, img, b.Min, draw.Src)
+	back := image.NewGray(b)
+	draw.Draw(back, b, verso, verso.Bounds().Min, draw.Src)
+
+	t1, _ := otsuThresholds3(histogram(new))
+	se := RectElement(maxMisregistration*2+1, maxMisregistration*2+1)
+	mask := Dilate(Otsu(back), se)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := new.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			if mask.Pix[i] == 0 && new.Pix[i] >= t1 {
+				new.Pix[i] = 255
+			}
+		}
+	}
+
+	return new, nil
+}
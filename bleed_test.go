@@ -0,0 +1,121 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestOtsuThresholds3(t *testing.T) {
+	var hist [256]int
+	for i := 20; i < 40; i++ {
+		hist[i] = 100
+	}
+	for i := 120; i < 140; i++ {
+		hist[i] = 300
+	}
+	for i := 210; i < 230; i++ {
+		hist[i] = 1000
+	}
+	t1, t2 := otsuThresholds3(hist)
+	if t1 < 40 || t1 > 120 || t2 < 140 || t2 > 210 {
+		t.Errorf("Thresholds %d and %d do not separate the three classes\n", t1, t2)
+	}
+}
+
+func TestSuppressBleed(t *testing.T) {
+	b := image.Rect(0, 0, 400, 200)
+	img := image.NewGray(b)
+	fillGray(img, b, 220)
+
+	var text, edges, bleed []image.Rectangle
+	for x := 20; x < 380; x += 40 {
+		// strokes with a dark core and faint edges
+		edge := image.Rect(x, 30, x+20, 80)
+		fillGray(img, edge, 150)
+		fillGray(img, edge.Inset(2), 40)
+		edges = append(edges, image.Rect(x, 30, x+2, 80))
+		text = append(text, edge.Inset(2))
+		// bleed-through of the same faintness
+		r := image.Rect(x, 120, x+20, 170)
+		fillGray(img, r, 150)
+		bleed = append(bleed, r)
+	}
+
+	clean := SuppressBleed(img)
+	for _, r := range text {
+		if !grayAll(clean, r, 40) {
+			t.Errorf("Text at %v was changed\n", r)
+		}
+	}
+	for _, r := range edges {
+		if !grayAll(clean, r, 150) {
+			t.Errorf("Edge of text at %v was changed\n", r)
+		}
+	}
+	for _, r := range bleed {
+		if !grayAll(clean, r, 255) {
+			t.Errorf("Bleed-through at %v was not removed\n", r)
+		}
+	}
+
+	pg, err := decode("testdata/pg1.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	before := Otsu(pg)
+	after := Otsu(SuppressBleed(pg))
+	pb := pg.Bounds()
+	if p, q := blackProportion(after, pb), blackProportion(before, pb); p < q*0.95 {
+		t.Errorf("Proportion of black pixels fell from %.3f to %.3f after suppressing bleed-through\n", q, p)
+	}
+}
+
+func TestSuppressBleedVerso(t *testing.T) {
+	b := image.Rect(0, 0, 400, 200)
+	img := image.NewGray(b)
+	fillGray(img, b, 220)
+	verso := image.NewGray(b)
+	fillGray(verso, b, 220)
+
+	// text on the recto, some over the verso text
+	text := []image.Rectangle{image.Rect(20, 20, 60, 60), image.Rect(200, 120, 240, 160)}
+	for _, r := range text {
+		fillGray(img, r, 40)
+	}
+	// verso text showing through, slightly out of register
+	var bleed []image.Rectangle
+	for _, r := range []image.Rectangle{image.Rect(100, 20, 140, 60), image.Rect(190, 110, 250, 170)} {
+		fillGray(verso, r, 30)
+		bleed = append(bleed, r.Add(image.Pt(1, -2)))
+	}
+	for _, r := range bleed {
+		fillGray(img, r, 120)
+	}
+	for _, r := range text {
+		fillGray(img, r, 40)
+	}
+
+	clean, err := SuppressBleedVerso(img, verso)
+	if err != nil {
+		t.Fatalf("Error suppressing bleed-through: %v\n", err)
+	}
+	for _, r := range text {
+		if !grayAll(clean, r, 40) {
+			t.Errorf("Text at %v was changed\n", r)
+		}
+	}
+	if !grayAll(clean, bleed[0], 255) {
+		t.Errorf("Bleed-through at %v was not removed\n", bleed[0])
+	}
+	if !grayAll(clean, image.Rect(190, 110, 199, 170).Add(image.Pt(1, -2)), 255) {
+		t.Errorf("Bleed-through around text at %v was not removed\n", text[1])
+	}
+
+	if _, err := SuppressBleedVerso(img, image.NewGray(image.Rect(0, 0, 300, 200))); err == nil {
+		t.Errorf("No error returned for a verso of a different size\n")
+	}
+}
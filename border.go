@@ -0,0 +1,106 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+)
+
+// RemoveBorder finds solid black areas which touch the edge of a
+// binarised image, such as the borders left around microfilm and
+// photocopy scans, and turns them white. Only areas at least
+// minwidth pixels thick in both directions are found, so text is
+// left alone unless it touches a border, in which case the parts
+// within minwidth pixels of the border are also whitened. A good
+// minwidth is given by EstimateSizes.
+//
+// The image is opened with a minwidth square, which removes
+// anything thinner, and the connected components of what remains
+// which touch the edge of the image are taken as the border. This
+// is then dilated by the same square again, to cover the ragged
+// inner edge of the border.
+func RemoveBorder(img *image.Gray, minwidth int) *image.Gray {
+	mask, found := borderMask(img, minwidth)
+	if !found {
+		return img
+	}
+	return whitenMask(img, mask)
+}
+
+// RemoveBorderOtsu removes the black borders of a greyscale image
+// from img, which is usually a binarised version of it, turning
+// them white. The borders are found as described for RemoveBorder
+// in a copy of gray binarised with Otsu's method, as adaptive
+// methods such as Sauvola break up the inside of large dark areas
+// into white and speckle, so that a border can't be found in their
+// output. img and gray must have the same bounds.
+func RemoveBorderOtsu(img *image.Gray, gray *image.Gray, minwidth int) *image.Gray {
+	mask, found := borderMask(Otsu(gray), minwidth)
+	if !found {
+		return img
+	}
+	return whitenMask(img, mask)
+}
+
+// removeBorderGray removes any black border from a greyscale image
+// with RemoveBorderOtsu
+func removeBorderGray(img *image.Gray, minwidth int) *image.Gray {
+	return RemoveBorderOtsu(img, img, minwidth)
+}
+
+// borderMask finds the black borders of a binarised image as
+// described for RemoveBorder, returning a mask which is black where
+// they are, and whether any were found
+func borderMask(img *image.Gray, minwidth int) (*image.Gray, bool) {
+	b := img.Bounds()
+	se := RectElement(minwidth, minwidth)
+	solid := Open(img, se)
+
+	labels, comps := Components(solid, EightConnected)
+	isborder := make([]bool, len(comps)+1)
+	var found bool
+	for _, c := range comps {
+		if c.Bounds.Min.X == b.Min.X || c.Bounds.Min.Y == b.Min.Y || c.Bounds.Max.X == b.Max.X || c.Bounds.Max.Y == b.Max.Y {
+			isborder[c.Label] = true
+			found = true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	mask := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := mask.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			if isborder[labels.LabelAt(x, y)] {
+				mask.Pix[i] = 0
+			} else {
+				mask.Pix[i] = 255
+			}
+		}
+	}
+	return Dilate(mask, se), true
+}
+
+// whitenMask returns a copy of an image with the pixels which are
+// black in mask turned white
+func whitenMask(img *image.Gray, mask *image.Gray) *image.Gray {
+	b := img.Bounds()
+	new := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := new.PixOffset(b.Min.X, y)
+		j := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i, j = x+1, i+1, j+1 {
+			if mask.Pix[i] == 0 {
+				new.Pix[i] = 255
+			} else {
+				new.Pix[i] = img.Pix[j]
+			}
+		}
+	}
+
+	return new
+}
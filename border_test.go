@@ -0,0 +1,123 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestRemoveBorder(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	b := bin.Bounds()
+	minwidth := EstimateSizes(img).Border
+
+	if !imgsequal(RemoveBorder(bin, minwidth), bin) {
+		t.Errorf("Image without a border was changed\n")
+	}
+
+	// add a thick border with a ragged inner edge down the left and
+	// along the top
+	bordered := Otsu(img)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			ragged := (x + y) % 7
+			if x < 80+ragged || y < 50+ragged {
+				bordered.SetGray(x, y, color.Gray{0})
+			}
+		}
+	}
+
+	clean := RemoveBorder(bordered, minwidth)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if x < 80 || y < 50 {
+				if clean.GrayAt(x, y).Y != 255 {
+					t.Fatalf("Border pixel %d,%d not removed\n", x, y)
+				}
+			} else if x > 80+minwidth*2 && y > 50+minwidth*2 && clean.GrayAt(x, y) != bin.GrayAt(x, y) {
+				t.Fatalf("Pixel %d,%d away from the border was changed\n", x, y)
+			}
+		}
+	}
+
+	// the border should no longer stop the content area being found
+	want, _ := WipeEdges(bin, 5, 0.02, 30)
+	got, _ := WipeEdges(clean, 5, 0.02, 30)
+	if got.Min.X < 80 || got.Max.X != want.Max.X {
+		t.Errorf("Content area after removing border is %v, expected around %v\n", got, want)
+	}
+}
+
+func TestRemoveBorderGray(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	minwidth := EstimateSizes(img).Border
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	b := gray.Bounds()
+
+	if !imgsequal(removeBorderGray(gray, minwidth), gray) {
+		t.Errorf("Image without a border was changed\n")
+	}
+
+	// add a dark grey border down the right hand side
+	bordered := image.NewGray(b)
+	copy(bordered.Pix, gray.Pix)
+	fillGray(bordered, image.Rect(b.Max.X-80, b.Min.Y, b.Max.X, b.Max.Y), 40)
+
+	clean := removeBorderGray(bordered, minwidth)
+	if !grayAll(clean, image.Rect(b.Max.X-80, b.Min.Y, b.Max.X, b.Max.Y), 255) {
+		t.Errorf("Border was not removed\n")
+	}
+	inner := image.Rect(b.Min.X, b.Min.Y, b.Max.X-80-minwidth*2, b.Max.Y)
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		for x := inner.Min.X; x < inner.Max.X; x++ {
+			if clean.GrayAt(x, y) != gray.GrayAt(x, y) {
+				t.Fatalf("Pixel %d,%d away from the border was changed\n", x, y)
+			}
+		}
+	}
+}
+
+func TestRemoveBorderOtsu(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	s := EstimateSizes(img)
+	b := img.Bounds()
+
+	// add a dark, slightly uneven border down the left hand side, and
+	// binarise it with Sauvola as preproc does
+	border := image.Rect(b.Min.X, b.Min.Y, b.Min.X+80, b.Max.Y)
+	for y := border.Min.Y; y < border.Max.Y; y++ {
+		for x := border.Min.X; x < border.Max.X; x++ {
+			img.SetGray(x, y, color.Gray{uint8(5 + (x*7+y*3)%21)})
+		}
+	}
+	bin := IntegralSauvola(img, 0.5, s.Binarize)
+
+	clean := RemoveBorderOtsu(bin, img, s.Border)
+	if p := blackProportion(clean, border); p != 0 {
+		t.Errorf("Proportion of border left after removal %.3f, expected 0\n", p)
+	}
+	inner := image.Rect(border.Max.X+s.Border*2, b.Min.Y, b.Max.X, b.Max.Y)
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		for x := inner.Min.X; x < inner.Max.X; x++ {
+			if clean.GrayAt(x, y) != bin.GrayAt(x, y) {
+				t.Fatalf("Pixel %d,%d away from the border was changed\n", x, y)
+			}
+		}
+	}
+}
@@ -2,7 +2,8 @@
 // Use of this source code is governed by the GPLv3
 // license that can be found in the LICENSE file.
 
-// binarize does fast Integral Image sauvola binarisation on an image
+// binarize does fast Integral Image binarisation on an image, using
+// sauvola or another registered algorithm
 package main
 
 import (
@@ -14,23 +15,23 @@ import (
 	"image/png"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"rescribe.xyz/preproc"
 )
 
-// TODO: do more testing to see how good this assumption is
-func autowsize(bounds image.Rectangle) int {
-	return bounds.Dx() / 60
-}
-
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: binarize [-k num] [-t type] [-w num] inimg outimg\n")
+		fmt.Fprintf(os.Stderr, "Usage: binarize [-a algorithm] [-bleed] [-k num] [-t type] [-verso img] [-w num] inimg outimg\n")
 		flag.PrintDefaults()
 	}
-	wsize := flag.Int("w", 0, "Window size for sauvola algorithm. Set automatically based on resolution if not set.")
-	ksize := flag.Float64("k", 0.5, "K for sauvola algorithm. This controls the overall threshold level. Set it lower for very light text (try 0.1 or 0.2).")
-	btype := flag.String("t", "binary", "Type of threshold. binary or zeroinv are currently implemented.")
+	alg := flag.String("a", "sauvola", "Binarization algorithm to use. Available algorithms: "+strings.Join(preproc.Binarizers(), ", ")+".")
+	wsize := flag.Int("w", 0, "Window size for binarization algorithm. Set automatically based on the size of the text if not set.")
+	ksize := flag.String("k", "", "K for the binarization algorithm. This controls the overall threshold level, and means something different to each algorithm. If not set the algorithm's default is used, which is 0.5 for sauvola, otsusauvola and wolf, and -0.2 for niblack, which needs a negative k. For sauvola set it lower for very light text (try 0.1 or 0.2). Set it to auto to choose a value automatically, which only the sauvola and otsusauvola algorithms can do.")
+	bleed := flag.Bool("bleed", false, "Suppress faint bleed-through of text from the other side of the page before binarization.")
+	verso := flag.String("verso", "", "Image of the other side of the page, mirrored and aligned with inimg, to suppress bleed-through of its text before binarization.")
+	btype := flag.String("t", "binary", "Type of threshold. One of: "+strings.Join(preproc.BinTypes, ", ")+".")
 	flag.Parse()
 	if flag.NArg() < 2 {
 		flag.Usage()
@@ -50,8 +51,28 @@ func main() {
 	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
 	draw.Draw(gray, b, img, b.Min, draw.Src)
 
+	if *verso != "" {
+		vf, err := os.Open(*verso)
+		if err != nil {
+			log.Fatalf("Could not open file %s: %v\n", *verso, err)
+		}
+		defer vf.Close()
+		vimg, _, err := image.Decode(vf)
+		if err != nil {
+			log.Fatalf("Could not decode image: %v\n", err)
+		}
+		gray, err = preproc.SuppressBleedVerso(gray, vimg)
+		if err != nil {
+			log.Fatalf("Could not suppress bleed-through from verso %s: %v\n", *verso, err)
+		}
+	}
+
+	if *bleed {
+		gray = preproc.SuppressBleed(gray)
+	}
+
 	if *wsize == 0 {
-		*wsize = autowsize(b)
+		*wsize = preproc.EstimateSizes(gray).Binarize
 		log.Printf("Set window size to %d\n", *wsize)
 	}
 
@@ -59,16 +80,34 @@ func main() {
 		*wsize++
 	}
 
-	// TODO: come up with a way to set a good ksize automatically
-
-	var thresh image.Image
-	thresh = preproc.IntegralSauvola(gray, *ksize, *wsize)
-
-	if *btype == "zeroinv" {
-		thresh, err = preproc.BinToZeroInv(thresh.(*image.Gray), img.(*image.RGBA))
+	var bin preproc.Binarizer
+	if *ksize == "auto" {
+		var k float64
+		bin, k, err = preproc.NewAutoKBinarizer(*alg, *wsize, gray)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Set k to %0.2f\n", k)
+	} else {
+		k, err := preproc.DefaultK(*alg)
+		if *ksize != "" {
+			k, err = strconv.ParseFloat(*ksize, 64)
+			if err != nil {
+				log.Fatalf("Could not parse k value %s: %v\n", *ksize, err)
+			}
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
+		bin, err = preproc.NewBinarizer(*alg, preproc.BinarizerParams{K: k, Wsize: *wsize})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	thresh, err := preproc.BinToType(*btype, bin.Binarize(gray), img)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	f, err = os.Create(flag.Arg(1))
@@ -0,0 +1,67 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+// deskew finds the skew angle of the text in an image and rotates
+// the image to straighten it
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+
+	"rescribe.xyz/preproc"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: deskew [-max degrees] [-p degrees] inimg outimg\n")
+		fmt.Fprintf(os.Stderr, "Straightens an image whose text is skewed.\n")
+		flag.PrintDefaults()
+	}
+	maxangle := flag.Float64("max", 5, "Maximum skew angle to check for, in degrees.")
+	precision := flag.Float64("p", 0.05, "Precision to find the skew angle to, in degrees.")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *precision <= 0 {
+		log.Fatalf("Precision must be greater than 0, not %v\n", *precision)
+	}
+	if *maxangle < 0 {
+		log.Fatalf("Maximum skew angle must not be negative, not %v\n", *maxangle)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	defer f.Close()
+	if err != nil {
+		log.Fatalf("Could not open file %s: %v\n", flag.Arg(0), err)
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		log.Fatalf("Could not decode image: %v\n", err)
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	angle := preproc.FindSkew(preproc.Otsu(rgba), *maxangle, *precision)
+	log.Printf("Found skew of %0.2f degrees\n", angle)
+
+	f, err = os.Create(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("Could not create file %s: %v\n", flag.Arg(1), err)
+	}
+	defer f.Close()
+	err = png.Encode(f, preproc.RotateRGBA(rgba, -angle))
+	if err != nil {
+		log.Fatalf("Could not encode image: %v\n", err)
+	}
+}
@@ -5,8 +5,6 @@
 // preproc runs binarisation and wipe preprocessing on an image
 package main
 
-// TODO: come up with a way to set a good ksize automatically
-
 import (
 	"flag"
 	"fmt"
@@ -16,36 +14,52 @@ import (
 	"image/png"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"rescribe.xyz/preproc"
 )
 
-// TODO: do more testing to see how good this assumption is
-func autowsize(bounds image.Rectangle) int {
-	return bounds.Dx() / 60
-}
-
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: preproc [-bt bintype] [-bw winsize] [-k num] [-m minperc] [-nowipe] [-wt wipethresh] [-ws wipesize] inimg outimg\n")
+		fmt.Fprintf(os.Stderr, "Usage: preproc [-autorotate] [-rotconf conf] [-ba algorithm] [-bt bintype] [-border] [-bw winsize] [-crop] [-deskew] [-despeckle] [-ds size] [-dwhite] [-flatten] [-fw winsize] [-k num] [-keepimages] [-margin px] [-m minperc] [-nowipe] [-rules] [-spread] [-wt wipethresh] [-ws wipesize] inimg outimg\n")
 		fmt.Fprintf(os.Stderr, "Binarize and preprocess an image\n")
 		flag.PrintDefaults()
 	}
-	binwsize := flag.Int("bw", 0, "Window size for sauvola binarization algorithm. Set automatically based on resolution if not set.")
-	ksize := flag.Float64("k", 0.5, "K for sauvola binarization algorithm. This controls the overall threshold level. Set it lower for very light text (try 0.1 or 0.2).")
-	btype := flag.String("bt", "binary", "Type of binarization threshold. binary or zeroinv are currently implemented.")
+	binalg := flag.String("ba", "sauvola", "Binarization algorithm to use. Available algorithms: "+strings.Join(preproc.Binarizers(), ", ")+".")
+	binwsize := flag.Int("bw", 0, "Window size for binarization algorithm. Set automatically based on the size of the text if not set.")
+	ksize := flag.String("k", "", "K for the binarization algorithm. This controls the overall threshold level, and means something different to each algorithm. If not set the algorithm's default is used, which is 0.5 for sauvola, otsusauvola and wolf, and -0.2 for niblack, which needs a negative k. For sauvola set it lower for very light text (try 0.1 or 0.2). Set it to auto to choose a value automatically, which only the sauvola and otsusauvola algorithms can do.")
+	btype := flag.String("bt", "binary", "Type of binarization threshold. One of: "+strings.Join(preproc.BinTypes, ", ")+".")
 	min := flag.Int("m", 30, "Minimum percentage of the image width for the content width calculation to be considered valid.")
+	border := flag.Bool("border", false, "Remove black borders, such as from microfilm or photocopy scans, before wiping or cropping.")
 	nowipe := flag.Bool("nowipe", false, "Disable wiping completely.")
-	wipewsize := flag.Int("ws", 5, "Window size for wiping algorithm.")
+	wipewsize := flag.Int("ws", 5, "Window size for wiping algorithm. Set to 0 to choose automatically based on the size of the text.")
 	thresh := flag.Float64("wt", 0.05, "Threshold for the wiping algorithm to determine the proportion of black pixels below which a window is determined to be the edge.")
 	vmin := flag.Int("vm", 30, "Minimum percentage of the image height for the content width calculation to be considered valid.")
 	vthresh := flag.Float64("vt", 0.005, "Threshold for the proportion of black pixels below which a vertical wipe window is determined to be the edge. Higher means more aggressive wiping.")
-	vwsize := flag.Int("vw", 120, "Window size for vertical mask finding algorithm. Should be set to approximately line height + largest expected gap.")
+	vwsize := flag.Int("vw", 120, "Window size for vertical mask finding algorithm. Should be set to approximately line height + largest expected gap. Set to 0 to choose automatically based on the size of the text.")
+	autorotate := flag.Bool("autorotate", false, "Rotate the image by a multiple of 90 degrees if the text is not the right way up.")
+	rotconf := flag.Float64("rotconf", 0.1, "Minimum confidence, from 0 to 1, in the orientation found for -autorotate to rotate the image. Blank pages and pictures give a low confidence.")
+	deskew := flag.Bool("deskew", false, "Straighten the image if the text is skewed, before wiping.")
+	despeckle := flag.Bool("despeckle", false, "Remove specks of noise after binarization.")
+	dsize := flag.Int("ds", 0, "Largest size of speck in pixels to remove with -despeckle. Set automatically based on the size of the text if not set.")
+	dwhite := flag.Bool("dwhite", false, "Also fill white specks, such as holes in strokes, when despeckling.")
+	flatten := flag.Bool("flatten", false, "Even out uneven lighting, such as the shadow of a gutter, before binarization.")
+	fwsize := flag.Int("fw", 0, "Window size for -flatten. Should be larger than the characters. Set automatically based on the size of the text if not set.")
+	rules := flag.Bool("rules", false, "Remove long horizontal and vertical lines, such as table rulings and underlines, before wiping.")
+	keepimages := flag.Bool("keepimages", false, "Keep illustrations and other areas which are not text in greyscale, rather than binarising them. Any outside the content area are still wiped.")
+	crop := flag.Bool("crop", false, "Crop the image to the content area, rather than wiping outside it.")
+	spread := flag.Bool("spread", false, "Split a double page spread at the gutter, and process each page separately, saving them as outimg_left and outimg_right.")
+	margin := flag.Int("margin", 0, "Number of pixels around the content area to keep when cropping.")
 	flag.Parse()
 	if flag.NArg() < 2 {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if *fwsize < 0 {
+		log.Fatalf("Flatten window size must not be negative, not %d\n", *fwsize)
+	}
 
 	f, err := os.Open(flag.Arg(0))
 	defer f.Close()
@@ -58,42 +72,181 @@ func main() {
 	}
 	b := img.Bounds()
 	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
-	draw.Draw(gray, b, img, b.Min, draw.Src)
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Src)
 
-	if *binwsize == 0 {
-		*binwsize = autowsize(b)
+	if *autorotate {
+		rotation, conf := preproc.FindOrientation(preproc.Otsu(gray))
+		log.Printf("Found orientation of %d degrees (confidence %0.2f)\n", rotation, conf)
+		if rotation != 0 && conf < *rotconf {
+			log.Printf("Not rotating, as the confidence is below %0.2f\n", *rotconf)
+		} else if rotation != 0 {
+			log.Print("Rotating")
+			gray = preproc.Rotate90(gray, rotation)
+			rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+			draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+			img = preproc.Rotate90RGBA(rgba, rotation)
+			b = img.Bounds()
+		}
 	}
 
-	if *binwsize%2 == 0 {
-		*binwsize++
-	}
+	// process binarizes and wipes an image, saving it to outpath
+	process := func(img image.Image, gray *image.Gray, outpath string) {
+		b := img.Bounds()
 
-	log.Print("Binarising")
-	var clean, threshimg, vclean image.Image
-	threshimg = preproc.IntegralSauvola(gray, *ksize, *binwsize)
+		binw, wipew, vw, ds, fw := *binwsize, *wipewsize, *vwsize, *dsize, *fwsize
+		bordersize, rulelen := 0, 0
+		if binw == 0 || wipew == 0 || vw == 0 || (*despeckle && ds == 0) || (*flatten && fw == 0) || *border || *rules {
+			sizes := preproc.EstimateSizes(gray)
+			bordersize, rulelen = sizes.Border, sizes.Rule
+			if binw == 0 {
+				binw = sizes.Binarize
+				log.Printf("Set binarization window size to %d\n", binw)
+			}
+			if wipew == 0 {
+				wipew = sizes.Wipe
+				log.Printf("Set wipe window size to %d\n", wipew)
+			}
+			if vw == 0 {
+				vw = sizes.VWipe
+				log.Printf("Set vertical wipe window size to %d\n", vw)
+			}
+			if ds == 0 {
+				ds = sizes.Despeckle
+				if *despeckle {
+					log.Printf("Set despeckle size to %d\n", ds)
+				}
+			}
+			if fw == 0 {
+				fw = sizes.Flatten
+				if *flatten {
+					log.Printf("Set flatten window size to %d\n", fw)
+				}
+			}
+		}
 
-	if *btype == "zeroinv" {
-		threshimg, err = preproc.BinToZeroInv(threshimg.(*image.Gray), img.(*image.RGBA))
+		if *flatten {
+			log.Print("Flattening background")
+			gray = preproc.FlattenBackground(gray, fw)
+		}
+
+		if binw%2 == 0 {
+			binw++
+		}
+
+		var bin preproc.Binarizer
+		if *ksize == "auto" {
+			var k float64
+			var err error
+			bin, k, err = preproc.NewAutoKBinarizer(*binalg, binw, gray)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Set k to %0.2f\n", k)
+		} else {
+			k, err := preproc.DefaultK(*binalg)
+			if *ksize != "" {
+				k, err = strconv.ParseFloat(*ksize, 64)
+				if err != nil {
+					log.Fatalf("Could not parse k value %s: %v\n", *ksize, err)
+				}
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			bin, err = preproc.NewBinarizer(*binalg, preproc.BinarizerParams{K: k, Wsize: binw})
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		log.Print("Binarising")
+		var clean, threshimg, vclean *image.Gray
+		threshimg = bin.Binarize(gray)
+
+		if *deskew {
+			angle := preproc.FindSkew(threshimg, 5, 0.05)
+			log.Printf("Found skew of %0.2f degrees\n", angle)
+			if angle != 0 {
+				log.Print("Deskewing")
+				gray = preproc.Rotate(gray, -angle)
+				rgba := image.NewRGBA(gray.Bounds())
+				draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+				img = preproc.RotateRGBA(rgba, -angle)
+				b = img.Bounds()
+				threshimg = bin.Binarize(gray)
+			}
+		}
+
+		if *despeckle {
+			log.Print("Despeckling")
+			threshimg = preproc.Despeckle(threshimg, ds, *dwhite)
+		}
+
+		if *border && (*crop || !*nowipe) {
+			log.Print("Removing border")
+			threshimg = preproc.RemoveBorderOtsu(threshimg, gray, bordersize)
+		}
+
+		if *rules {
+			var found []preproc.Rule
+			threshimg, found = preproc.RemoveRules(threshimg, rulelen, bordersize)
+			log.Printf("Removed %d lines\n", len(found))
+		}
+
+		if *keepimages {
+			log.Print("Keeping non-text areas")
+			threshimg = preproc.KeepNonText(threshimg, img, preproc.FindNonText(threshimg, 0))
+		}
+
+		if *crop {
+			log.Print("Cropping")
+			r := preproc.ContentArea(threshimg, wipew, *thresh, *min, vw, *vthresh, *vmin)
+			r = r.Inset(-*margin).Intersect(threshimg.Bounds())
+			clean = preproc.Crop(threshimg, r)
+			cropped := image.NewRGBA(clean.Bounds())
+			draw.Draw(cropped, cropped.Bounds(), img, r.Min.Add(b.Min), draw.Src)
+			img = cropped
+		} else if !*nowipe {
+			log.Print("Wiping sides")
+			vclean = preproc.VWipe(threshimg, vw, *vthresh, *vmin)
+			clean = preproc.Wipe(vclean, wipew, *thresh, *min)
+		} else {
+			clean = threshimg
+		}
+
+		out, err := preproc.BinToType(*btype, clean, img)
 		if err != nil {
 			log.Fatal(err)
 		}
-	}
 
-	if !*nowipe {
-		log.Print("Wiping sides")
-		vclean = preproc.VWipe(threshimg.(*image.Gray), *vwsize, *vthresh, *vmin)
-		clean = preproc.Wipe(vclean.(*image.Gray), *wipewsize, *thresh, *min)
-	} else {
-		clean = threshimg
+		log.Printf("Saving %s\n", outpath)
+		f, err := os.Create(outpath)
+		if err != nil {
+			log.Fatalf("Could not create file %s: %v\n", outpath, err)
+		}
+		defer f.Close()
+		err = png.Encode(f, out)
+		if err != nil {
+			log.Fatalf("Could not encode image: %v\n", err)
+		}
 	}
 
-	f, err = os.Create(flag.Arg(1))
-	if err != nil {
-		log.Fatalf("Could not create file %s: %v\n", flag.Arg(1), err)
-	}
-	defer f.Close()
-	err = png.Encode(f, clean)
-	if err != nil {
-		log.Fatalf("Could not encode image: %v\n", err)
+	if *spread {
+		gutter, ok := preproc.FindGutter(preproc.Otsu(gray), 5, 0.05)
+		if ok {
+			log.Printf("Found gutter at %d\n", gutter)
+			ext := filepath.Ext(flag.Arg(1))
+			base := strings.TrimSuffix(flag.Arg(1), ext)
+			// gray starts at 0, 0, so the gutter is moved to the
+			// coordinates of img
+			leftimg, rightimg := preproc.SplitSpread(img, b.Min.X+gutter)
+			leftgray, rightgray := preproc.SplitSpread(gray, gutter)
+			process(leftimg, leftgray.(*image.Gray), base+"_left"+ext)
+			process(rightimg, rightgray.(*image.Gray), base+"_right"+ext)
+			return
+		}
+		log.Print("No gutter found, processing as a single page")
 	}
+
+	process(img, gray, flag.Arg(1))
 }
@@ -6,8 +6,6 @@
 // levels, preprocessing and saving each version
 package main
 
-// TODO: come up with a way to set a good ksize automatically
-
 import (
 	"flag"
 	"fmt"
@@ -17,40 +15,53 @@ import (
 	"image/png"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"rescribe.xyz/preproc"
 	"rescribe.xyz/integral"
 )
 
-// TODO: do more testing to see how good this assumption is
-func autowsize(bounds image.Rectangle) int {
-	return bounds.Dx() / 60
-}
-
 func main() {
-	ksizes := []float64{0.1, 0.2, 0.4, 0.5}
-
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: preprocmulti [-bt bintype] [-bw winsize] [-m minperc] [-nowipe] [-ws wipesize] inimg outbase\n")
+		fmt.Fprintf(os.Stderr, "Usage: preprocmulti [-ba algorithm] [-bt bintype] [-bw winsize] [-despeckle] [-ds size] [-k klist] [-m minperc] [-nowipe] [-ws wipesize] inimg outbase\n")
 		fmt.Fprintf(os.Stderr, "Binarize and preprocess an image, with multiple binarisation levels,\n")
 		fmt.Fprintf(os.Stderr, "saving images to outbase_bin{k}.png.\n")
-		fmt.Fprintf(os.Stderr, "Binarises with these levels for k: %v.\n", ksizes)
 		flag.PrintDefaults()
 	}
-	binwsize := flag.Int("bw", 0, "Window size for sauvola binarization algorithm. Set automatically based on resolution if not set.")
-	btype := flag.String("bt", "binary", "Type of binarization threshold. binary or zeroinv are currently implemented.")
+	klist := flag.String("k", "0.1,0.2,0.4,0.5", "Comma separated list of k values to binarize with. The default suits sauvola, otsusauvola and wolf, while niblack needs negative values, such as -0.1,-0.2,-0.3. A value of auto will choose a value automatically, saving the image to outbase_binauto.png. Only the sauvola and otsusauvola algorithms can choose k automatically.")
+	binalg := flag.String("ba", "sauvola", "Binarization algorithm to use. Available algorithms: "+strings.Join(preproc.Binarizers(), ", ")+".")
+	binwsize := flag.Int("bw", 0, "Window size for binarization algorithm. Set automatically based on the size of the text if not set.")
+	btype := flag.String("bt", "binary", "Type of binarization threshold. One of: "+strings.Join(preproc.BinTypes, ", ")+".")
 	min := flag.Int("m", 30, "Minimum percentage of the image width for the content width calculation to be considered valid.")
+	despeckle := flag.Bool("despeckle", false, "Remove specks of noise after binarization.")
+	dsize := flag.Int("ds", 0, "Largest size of speck in pixels to remove with -despeckle. Set automatically based on the size of the text if not set.")
 	nowipe := flag.Bool("nowipe", false, "Disable wiping completely.")
-	wipewsize := flag.Int("ws", 5, "Window size for wiping algorithm.")
+	wipewsize := flag.Int("ws", 5, "Window size for wiping algorithm. Set to 0 to choose automatically based on the size of the text.")
 	vmin := flag.Int("vm", 30, "Minimum percentage of the image height for the content width calculation to be considered valid.")
 	vthresh := flag.Float64("vt", 0.005, "Threshold for the proportion of black pixels below which a vertical wipe window is determined to be the edge. Higher means more aggressive wiping.")
-	vwsize := flag.Int("vw", 120, "Window size for vertical mask finding algorithm. Should be set to approximately line height + largest expected gap.")
+	vwsize := flag.Int("vw", 120, "Window size for vertical mask finding algorithm. Should be set to approximately line height + largest expected gap. Set to 0 to choose automatically based on the size of the text.")
 	flag.Parse()
 	if flag.NArg() < 2 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	var ksizes []float64
+	autok := false
+	for _, v := range strings.Split(*klist, ",") {
+		v = strings.TrimSpace(v)
+		if v == "auto" {
+			autok = true
+			continue
+		}
+		k, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Fatalf("Could not parse k value %s: %v\n", v, err)
+		}
+		ksizes = append(ksizes, k)
+	}
+
 	log.Printf("Opening %s\n", flag.Arg(0))
 	f, err := os.Open(flag.Arg(0))
 	defer f.Close()
@@ -63,48 +74,106 @@ func main() {
 	}
 	b := img.Bounds()
 
-	if *binwsize == 0 {
-		*binwsize = autowsize(b)
+	if *binwsize == 0 || *wipewsize == 0 || *vwsize == 0 || (*despeckle && *dsize == 0) {
+		sizes := preproc.EstimateSizes(img)
+		if *binwsize == 0 {
+			*binwsize = sizes.Binarize
+			log.Printf("Set binarization window size to %d\n", *binwsize)
+		}
+		if *wipewsize == 0 {
+			*wipewsize = sizes.Wipe
+			log.Printf("Set wipe window size to %d\n", *wipewsize)
+		}
+		if *vwsize == 0 {
+			*vwsize = sizes.VWipe
+			log.Printf("Set vertical wipe window size to %d\n", *vwsize)
+		}
+		if *dsize == 0 {
+			*dsize = sizes.Despeckle
+			if *despeckle {
+				log.Printf("Set despeckle size to %d\n", *dsize)
+			}
+		}
 	}
 
 	if *binwsize%2 == 0 {
 		*binwsize++
 	}
 
-	var clean, threshimg, vclean image.Image
-	log.Print("Precalculating integral images")
-	intImg := integral.NewImage(b)
-	draw.Draw(intImg, b, img, b.Min, draw.Src)
-	intSqImg := integral.NewSqImage(b)
-	draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+	var clean, threshimg, vclean *image.Gray
+	var intImg *integral.Image
+	var intSqImg *integral.SqImage
+	precalc := func() {
+		if intImg != nil {
+			return
+		}
+		log.Print("Precalculating integral images")
+		intImg = integral.NewImage(b)
+		draw.Draw(intImg, b, img, b.Min, draw.Src)
+		intSqImg = integral.NewSqImage(b)
+		draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+	}
 
-	for _, k := range ksizes {
-		log.Print("Binarising")
-		threshimg = preproc.PreCalcedSauvola(*intImg, *intSqImg, img, k, *binwsize)
+	// the last pass is for the estimated k value, if requested
+	for i := 0; i <= len(ksizes); i++ {
+		auto := i == len(ksizes)
+		if auto && !autok {
+			break
+		}
 
-		if *btype == "zeroinv" {
-			threshimg, err = preproc.BinToZeroInv(threshimg.(*image.Gray), img.(*image.RGBA))
+		var bin preproc.Binarizer
+		var k float64
+		if auto {
+			bin, k, err = preproc.NewAutoKBinarizer(*binalg, *binwsize, img)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Set k to %0.2f\n", k)
+		} else {
+			k = ksizes[i]
+			bin, err = preproc.NewBinarizer(*binalg, preproc.BinarizerParams{K: k, Wsize: *binwsize})
 			if err != nil {
 				log.Fatal(err)
 			}
 		}
 
+		log.Print("Binarising")
+		if ib, ok := bin.(preproc.IntegralBinarizer); ok {
+			precalc()
+			threshimg = ib.BinarizeIntegral(*intImg, *intSqImg, img)
+		} else {
+			threshimg = bin.Binarize(img)
+		}
+
+		if *despeckle {
+			log.Print("Despeckling")
+			threshimg = preproc.Despeckle(threshimg, *dsize, false)
+		}
+
 		if !*nowipe {
 			log.Print("Wiping sides")
-			vclean = preproc.VWipe(threshimg.(*image.Gray), *vwsize, *vthresh, *vmin)
-			clean = preproc.Wipe(vclean.(*image.Gray), *wipewsize, k*0.02, *min)
+			vclean = preproc.VWipe(threshimg, *vwsize, *vthresh, *vmin)
+			clean = preproc.Wipe(vclean, *wipewsize, k*0.02, *min)
 		} else {
 			clean = threshimg
 		}
 
+		out, err := preproc.BinToType(*btype, clean, img)
+		if err != nil {
+			log.Fatal(err)
+		}
+
 		savefn := fmt.Sprintf("%s_bin%0.1f.png", flag.Arg(1), k)
+		if auto {
+			savefn = fmt.Sprintf("%s_binauto.png", flag.Arg(1))
+		}
 		log.Printf("Saving %s\n", savefn)
 		f, err = os.Create(savefn)
 		if err != nil {
 			log.Fatalf("Could not create file %s: %v\n", savefn, err)
 		}
 		defer f.Close()
-		err = png.Encode(f, clean)
+		err = png.Encode(f, out)
 		if err != nil {
 			log.Fatalf("Could not encode image: %v\n", err)
 		}
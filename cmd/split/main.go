@@ -0,0 +1,74 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+// split finds the gutter of a double page spread and splits it into
+// separate left and right page images
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+
+	"rescribe.xyz/preproc"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: split [-t thresh] [-w wsize] inimg outbase\n")
+		fmt.Fprintf(os.Stderr, "Splits a double page spread into two images, saved as\n")
+		fmt.Fprintf(os.Stderr, "outbase_left.png and outbase_right.png.\n")
+		flag.PrintDefaults()
+	}
+	thresh := flag.Float64("t", 0.05, "Threshold for the proportion of black pixels below which a window is determined to be the gutter.")
+	wsize := flag.Int("w", 5, "Window size for gutter finding algorithm.")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	defer f.Close()
+	if err != nil {
+		log.Fatalf("Could not open file %s: %v\n", flag.Arg(0), err)
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		log.Fatalf("Could not decode image: %v\n", err)
+	}
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Src)
+
+	gutter, ok := preproc.FindGutter(preproc.Otsu(gray), *wsize, *thresh)
+	if !ok {
+		log.Fatalf("No gutter found in %s\n", flag.Arg(0))
+	}
+	log.Printf("Found gutter at %d\n", gutter)
+
+	// gray starts at 0, 0, so the gutter is moved to the coordinates
+	// of img
+	left, right := preproc.SplitSpread(img, b.Min.X+gutter)
+	for _, p := range []struct {
+		img  image.Image
+		name string
+	}{{left, "left"}, {right, "right"}} {
+		fn := fmt.Sprintf("%s_%s.png", flag.Arg(1), p.name)
+		f, err := os.Create(fn)
+		if err != nil {
+			log.Fatalf("Could not create file %s: %v\n", fn, err)
+		}
+		defer f.Close()
+		err = png.Encode(f, p.img)
+		if err != nil {
+			log.Fatalf("Could not encode image: %v\n", err)
+		}
+	}
+}
@@ -17,23 +17,35 @@ import (
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: wipe inimg outimg\n")
+		fmt.Fprintf(os.Stderr, "Usage: wipe [-border] [-crop] [-margin px] inimg outimg\n")
 		fmt.Fprintf(os.Stderr, "Wipes the sections of an image which are outside the content area.\n")
+		fmt.Fprintf(os.Stderr, "With -crop the image is instead cropped to the content area.\n")
 		flag.PrintDefaults()
 	}
 	hmin := flag.Int("hm", 30, "Minimum percentage of the image width for the content width calculation to be considered valid.")
 	thresh := flag.Float64("ht", 0.05, "Threshold for the proportion of black pixels below which a window is determined to be the edge. Higher means more aggressive wiping.")
-	wsize := flag.Int("hw", 5, "Window size for mask finding algorithm.")
+	wsize := flag.Int("hw", 5, "Window size for mask finding algorithm. Set to 0 to choose automatically based on the size of the text.")
 	vmin := flag.Int("vm", 30, "Minimum percentage of the image height for the content width calculation to be considered valid.")
 	vthresh := flag.Float64("vt", 0.005, "Threshold for the proportion of black pixels below which a vertical wipe window is determined to be the edge. Higher means more aggressive wiping.")
-	vwsize := flag.Int("vw", 120, "Window size for vertical mask finding algorithm. Should be set to approximately line height + largest expected gap.")
+	vwsize := flag.Int("vw", 120, "Window size for vertical mask finding algorithm. Should be set to approximately line height + largest expected gap. Set to 0 to choose automatically based on the size of the text.")
+	crop := flag.Bool("crop", false, "Crop the image to the content area, rather than wiping outside it.")
+	margin := flag.Int("margin", 0, "Number of pixels around the content area to keep when cropping.")
+	border := flag.Bool("border", false, "Remove any black border around the image, such as from a microfilm or photocopy scan, before finding the content area.")
 	flag.Parse()
 	if flag.NArg() < 2 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	err := preproc.WipeFile(flag.Arg(0), flag.Arg(1), *wsize, *thresh, *hmin, *vwsize, *vthresh, *vmin)
+	if *crop {
+		err := preproc.CropFile(flag.Arg(0), flag.Arg(1), *wsize, *thresh, *hmin, *vwsize, *vthresh, *vmin, *margin, *border)
+		if err != nil {
+			log.Fatalf("Failed to crop image: %v\n", err)
+		}
+		return
+	}
+
+	err := preproc.WipeFileBorder(flag.Arg(0), flag.Arg(1), *wsize, *thresh, *hmin, *vwsize, *vthresh, *vmin, *border)
 	if err != nil {
 		log.Fatalf("Failed to wipe image: %v\n", err)
 	}
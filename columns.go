@@ -0,0 +1,72 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+
+	"rescribe.xyz/integral"
+)
+
+// FindColumns finds the columns of text within the area r of a
+// binarised image, such as the content area found by ContentArea.
+// It moves a wsize width vertical slice, covering the height of r,
+// across the area, in the same way as findedges, and treats any
+// stretch at least mingap pixels wide where the proportion of black
+// pixels stays at or below thresh as a gutter between columns. The
+// rectangles of the columns are returned from left to right, with
+// any blank space at the left and right of r removed.
+func FindColumns(img *image.Gray, r image.Rectangle, wsize int, thresh float64, mingap int) []image.Rectangle {
+	b := img.Bounds()
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, img, b.Min, draw.Src)
+
+	r = r.Intersect(b)
+	if r.Empty() {
+		return nil
+	}
+	if wsize < 1 {
+		wsize = 1
+	}
+
+	// blank[x] is true if the slice starting at x is below thresh
+	blank := make([]bool, r.Dx())
+	for x := r.Min.X; x < r.Max.X; x++ {
+		slice := image.Rect(x, r.Min.Y, x+wsize, r.Max.Y).Intersect(r)
+		blank[x-r.Min.X] = proportionRect(*intImg, slice) <= thresh
+	}
+
+	var cols []image.Rectangle
+	start := -1
+	for x := 0; x < len(blank); {
+		if !blank[x] {
+			if start == -1 {
+				start = x
+			}
+			x++
+			continue
+		}
+		gapstart := x
+		for x < len(blank) && blank[x] {
+			x++
+		}
+		// the last blank slice covers wsize pixels
+		gapend := x + wsize - 1
+		if gapend > len(blank) {
+			gapend = len(blank)
+		}
+		if start != -1 && (gapend-gapstart >= mingap || gapend == len(blank)) {
+			cols = append(cols, image.Rect(r.Min.X+start, r.Min.Y, r.Min.X+gapstart, r.Max.Y))
+			start = -1
+		}
+		x = gapend
+	}
+	if start != -1 {
+		cols = append(cols, image.Rect(r.Min.X+start, r.Min.Y, r.Max.X, r.Max.Y))
+	}
+
+	return cols
+}
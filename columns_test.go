@@ -0,0 +1,61 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestFindColumns(t *testing.T) {
+	left, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	right, err := decode("testdata/0002.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	leftbin, rightbin := Otsu(left), Otsu(right)
+	leftarea := ContentArea(leftbin, 5, 0.02, 30, 120, 0.005, 30)
+	rightarea := ContentArea(rightbin, 5, 0.02, 30, 120, 0.005, 30)
+
+	// put the content of both pages side by side, as two columns
+	gap := 60
+	w := leftarea.Dx() + gap + rightarea.Dx()
+	h := leftarea.Dy()
+	if rightarea.Dy() > h {
+		h = rightarea.Dy()
+	}
+	img := image.NewGray(image.Rect(0, 0, w+200, h+200))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.Gray{255}}, image.Point{}, draw.Src)
+	lr := image.Rect(100, 100, 100+leftarea.Dx(), 100+leftarea.Dy())
+	rr := image.Rect(lr.Max.X+gap, 100, lr.Max.X+gap+rightarea.Dx(), 100+rightarea.Dy())
+	draw.Draw(img, lr, leftbin, leftarea.Min, draw.Src)
+	draw.Draw(img, rr, rightbin, rightarea.Min, draw.Src)
+
+	cols := FindColumns(img, img.Bounds(), 5, 0.005, 30)
+	if len(cols) != 2 {
+		t.Fatalf("Found %d columns, expected 2: %v\n", len(cols), cols)
+	}
+	if cols[0].Max.X > rr.Min.X || cols[1].Min.X < lr.Max.X || cols[0].Max.X >= cols[1].Min.X {
+		t.Errorf("Columns %v do not meet at the gap between %d and %d\n", cols, lr.Max.X, rr.Min.X)
+	}
+	if cols[0].Min.X < 100-5 || cols[1].Max.X > rr.Max.X+5 {
+		t.Errorf("Columns %v include the margins\n", cols)
+	}
+
+	if cols := FindColumns(img, img.Bounds(), 5, 0.005, gap*2); len(cols) != 1 {
+		t.Errorf("Found %d columns with a minimum gap wider than the gap\n", len(cols))
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, 100, 100))
+	draw.Draw(blank, blank.Bounds(), &image.Uniform{color.Gray{255}}, image.Point{}, draw.Src)
+	if cols := FindColumns(blank, blank.Bounds(), 5, 0.005, 30); len(cols) != 0 {
+		t.Errorf("Found columns %v in a blank image\n", cols)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+)
+
+// Connectivity values for Components, which set whether pixels
+// which only touch diagonally are connected.
+const (
+	FourConnected  = 4
+	EightConnected = 8
+)
+
+// Component is a connected group of black pixels in an image.
+type Component struct {
+	Label     int             // Label of the component in the LabelImage
+	Bounds    image.Rectangle // Bounding box of the component
+	Area      int             // Number of pixels in the component
+	CentroidX float64         // Mean x position of the pixels
+	CentroidY float64         // Mean y position of the pixels
+}
+
+// LabelImage records which component each pixel of an image is a
+// part of. Pix holds the label of each pixel, laid out in the same
+// way as the Pix of an image.Gray, with 0 for pixels which are not
+// part of any component. Labels are stored as int32 rather than
+// int to halve the memory used for large pages.
+type LabelImage struct {
+	Pix    []int32
+	Stride int
+	Rect   image.Rectangle
+}
+
+// Bounds returns the bounds of the labelled image.
+func (l *LabelImage) Bounds() image.Rectangle {
+	return l.Rect
+}
+
+// LabelAt returns the label of the pixel at x, y, or 0 if it is not
+// part of a component or is outside the image.
+func (l *LabelImage) LabelAt(x, y int) int {
+	if !image.Pt(x, y).In(l.Rect) {
+		return 0
+	}
+	return int(l.Pix[(y-l.Rect.Min.Y)*l.Stride+(x-l.Rect.Min.X)])
+}
+
+// Components finds the connected components of black pixels in a
+// binarised image, using either FourConnected or EightConnected
+// connectivity. It returns an image of the label of each pixel, and
+// the statistics of each component. Labels start at 1, so the
+// component with label n is at index n-1.
+//
+// The classic two pass algorithm with a union-find structure is
+// used, so there is no recursion and the memory used only depends
+// on the size of the image.
+func Components(img *image.Gray, connectivity int) (*LabelImage, []Component) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	labels := &LabelImage{Pix: make([]int32, w*h), Stride: w, Rect: b}
+
+	// parent[n] is the label which label n has been merged into;
+	// index 0 is unused as 0 means background
+	parent := []int{0}
+	find := func(n int) int {
+		for parent[n] != n {
+			parent[n] = parent[parent[n]]
+			n = parent[n]
+		}
+		return n
+	}
+	union := func(a, b int) int {
+		a, b = find(a), find(b)
+		if a < b {
+			parent[b] = a
+			return a
+		}
+		parent[a] = b
+		return b
+	}
+
+	// first pass: give each pixel a provisional label, recording
+	// which labels are connected
+	for y := 0; y < h; y++ {
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x, i = x+1, i+1 {
+			if img.Pix[i] >= 128 {
+				continue
+			}
+			var neighbours [4]int
+			n := 0
+			if x > 0 {
+				neighbours[n] = int(labels.Pix[y*w+x-1])
+				n++
+			}
+			if y > 0 {
+				neighbours[n] = int(labels.Pix[(y-1)*w+x])
+				n++
+				if connectivity == EightConnected {
+					if x > 0 {
+						neighbours[n] = int(labels.Pix[(y-1)*w+x-1])
+						n++
+					}
+					if x < w-1 {
+						neighbours[n] = int(labels.Pix[(y-1)*w+x+1])
+						n++
+					}
+				}
+			}
+
+			label := 0
+			for _, l := range neighbours[:n] {
+				if l == 0 {
+					continue
+				}
+				if label == 0 {
+					label = find(l)
+				} else {
+					label = union(label, l)
+				}
+			}
+			if label == 0 {
+				label = len(parent)
+				parent = append(parent, label)
+			}
+			labels.Pix[y*w+x] = int32(label)
+		}
+	}
+
+	// second pass: replace each label with the final label of its
+	// set, numbered in order, and gather the statistics
+	final := make([]int, len(parent))
+	var comps []Component
+	var sumx, sumy []int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if labels.Pix[i] == 0 {
+				continue
+			}
+			root := find(int(labels.Pix[i]))
+			if final[root] == 0 {
+				comps = append(comps, Component{
+					Label:  len(comps) + 1,
+					Bounds: image.Rect(b.Min.X+x, b.Min.Y+y, b.Min.X+x+1, b.Min.Y+y+1),
+				})
+				sumx = append(sumx, 0)
+				sumy = append(sumy, 0)
+				final[root] = len(comps)
+			}
+			label := final[root]
+			labels.Pix[i] = int32(label)
+
+			c := &comps[label-1]
+			c.Area++
+			sumx[label-1] += x
+			sumy[label-1] += y
+			c.Bounds = c.Bounds.Union(image.Rect(b.Min.X+x, b.Min.Y+y, b.Min.X+x+1, b.Min.Y+y+1))
+		}
+	}
+
+	for i := range comps {
+		comps[i].CentroidX = float64(b.Min.X) + float64(sumx[i])/float64(comps[i].Area)
+		comps[i].CentroidY = float64(b.Min.Y) + float64(sumy[i])/float64(comps[i].Area)
+	}
+
+	return labels, comps
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// floodCount counts the components of an image with a simple flood
+// fill, to check Components against
+func floodCount(img *image.Gray, connectivity int) int {
+	b := img.Bounds()
+	seen := make(map[image.Point]bool)
+	var dirs []image.Point
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if (dx == 0 && dy == 0) || (connectivity == FourConnected && dx != 0 && dy != 0) {
+				continue
+			}
+			dirs = append(dirs, image.Pt(dx, dy))
+		}
+	}
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := image.Pt(x, y)
+			if img.GrayAt(x, y).Y >= 128 || seen[p] {
+				continue
+			}
+			n++
+			seen[p] = true
+			stack := []image.Point{p}
+			for len(stack) > 0 {
+				cur := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				for _, d := range dirs {
+					q := cur.Add(d)
+					if q.In(b) && !seen[q] && img.GrayAt(q.X, q.Y).Y < 128 {
+						seen[q] = true
+						stack = append(stack, q)
+					}
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestComponents(t *testing.T) {
+	img := whiteGray(image.Rect(0, 0, 20, 10))
+	black := color.Gray{0}
+	// a 3x3 square
+	for y := 1; y < 4; y++ {
+		for x := 1; x < 4; x++ {
+			img.SetGray(x, y, black)
+		}
+	}
+	// two pixels touching diagonally
+	img.SetGray(6, 1, black)
+	img.SetGray(7, 2, black)
+	// a U shape, whose arms are only joined at the bottom
+	for y := 1; y < 8; y++ {
+		img.SetGray(10, y, black)
+		img.SetGray(14, y, black)
+	}
+	for x := 10; x <= 14; x++ {
+		img.SetGray(x, 7, black)
+	}
+
+	cases := []struct {
+		connectivity int
+		areas        []int
+	}{
+		{FourConnected, []int{9, 1, 17, 1}},
+		{EightConnected, []int{9, 2, 17}},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%d", c.connectivity), func(t *testing.T) {
+			labels, comps := Components(img, c.connectivity)
+			if len(comps) != len(c.areas) {
+				t.Fatalf("Found %d components, expected %d\n", len(comps), len(c.areas))
+			}
+			for i, comp := range comps {
+				if comp.Area != c.areas[i] {
+					t.Errorf("Component %d has area %d, expected %d\n", i, comp.Area, c.areas[i])
+				}
+				if comp.Label != i+1 {
+					t.Errorf("Component %d has label %d\n", i, comp.Label)
+				}
+			}
+			if comps[0].Bounds != image.Rect(1, 1, 4, 4) || comps[0].CentroidX != 2 || comps[0].CentroidY != 2 {
+				t.Errorf("Square has bounds %v and centroid %0.1f,%0.1f\n", comps[0].Bounds, comps[0].CentroidX, comps[0].CentroidY)
+			}
+			if labels.LabelAt(10, 1) != labels.LabelAt(14, 1) {
+				t.Errorf("Arms of U shape have different labels\n")
+			}
+			if labels.LabelAt(0, 0) != 0 || labels.LabelAt(-1, -1) != 0 {
+				t.Errorf("Background pixels have a label\n")
+			}
+		})
+	}
+}
+
+func TestComponentsPage(t *testing.T) {
+	img, err := decode("testdata/pg2.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	for _, connectivity := range []int{FourConnected, EightConnected} {
+		_, comps := Components(bin, connectivity)
+		if expected := floodCount(bin, connectivity); len(comps) != expected {
+			t.Errorf("Found %d %d-connected components, expected %d\n", len(comps), connectivity, expected)
+		}
+	}
+}
+
+func TestComponentsLarge(t *testing.T) {
+	// a single snake filling a large image, which would overflow the
+	// stack with a recursive flood fill
+	size := 2000
+	img := whiteGray(image.Rect(0, 0, size, size))
+	area := 0
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			img.SetGray(x, y, color.Gray{0})
+			area++
+		}
+		if y+1 < size {
+			x := 0
+			if (y/2)%2 == 0 {
+				x = size - 1
+			}
+			img.SetGray(x, y+1, color.Gray{0})
+			area++
+		}
+	}
+	_, comps := Components(img, FourConnected)
+	if len(comps) != 1 || comps[0].Area != area {
+		t.Errorf("Found %d components, expected one of area %d\n", len(comps), area)
+	}
+}
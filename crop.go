@@ -0,0 +1,71 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+)
+
+// ContentArea finds the area of an image which contains content,
+// using the same detection as VWipe followed by Wipe. If the area
+// found in either direction is below the minimum % the full extent
+// of the image is used in that direction, just as the wipe would
+// leave the image untouched.
+func ContentArea(img *image.Gray, hwsize int, hthresh float64, hmin int, vwsize int, vthresh float64, vmin int) image.Rectangle {
+	r := img.Bounds()
+
+	vclean := img
+	if vr, narrow := VWipeEdges(img, vwsize, vthresh, vmin); !narrow {
+		r.Min.Y, r.Max.Y = vr.Min.Y, vr.Max.Y
+		vclean = sideways(wipesides(sideways(img), vr.Min.Y, vr.Max.Y))
+	}
+
+	if hr, narrow := WipeEdges(vclean, hwsize, hthresh, hmin); !narrow {
+		r.Min.X, r.Max.X = hr.Min.X, hr.Max.X
+	}
+
+	return r
+}
+
+// Crop returns a copy of the area r of an image, limited to the
+// image bounds. The returned image starts at 0, 0. To add a margin
+// around r, pass r.Inset(-margin).
+func Crop(img *image.Gray, r image.Rectangle) *image.Gray {
+	r = r.Intersect(img.Bounds())
+	new := image.NewGray(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(new, new.Bounds(), img, r.Min, draw.Src)
+	return new
+}
+
+// CropFile crops an image file to its content area, as found by
+// ContentArea, plus a margin. If border is true any black border is
+// first removed, as described for WipeFileBorder.
+// inPath: path of the input image.
+// outPath: path to save the output image.
+// hwsize: window size (width) for horizontal wipe algorithm, or 0 to use EstimateSizes.
+// hthresh: threshold for horizontal wipe algorithm.
+// hmin: minimum % of content area width to consider valid.
+// vwsize: window size (height) for vertical wipe algorithm, or 0 to use EstimateSizes.
+// vthresh: threshold for vertical wipe algorithm.
+// vmin: minimum % of content area height to consider valid.
+// margin: number of pixels around the content area to keep.
+// border: whether to remove any black border first.
+func CropFile(inPath string, outPath string, hwsize int, hthresh float64, hmin int, vwsize int, vthresh float64, vmin int, margin int, border bool) error {
+	gray, err := decodeGray(inPath)
+	if err != nil {
+		return err
+	}
+
+	hwsize, vwsize, bsize := wipeSizes(gray, hwsize, vwsize, border)
+	if border {
+		gray = removeBorderGray(gray, bsize)
+	}
+
+	r := ContentArea(gray, hwsize, hthresh, hmin, vwsize, vthresh, vmin)
+	cropped := Crop(gray, r.Inset(-margin))
+
+	return encodePNG(outPath, cropped)
+}
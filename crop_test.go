@@ -0,0 +1,66 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCrop(t *testing.T) {
+	cases := []struct {
+		filename string
+		wsize    int
+		thresh   float64
+		vwsize   int
+		vthresh  float64
+	}{
+		{"testdata/1727_GREENE_0048.png", 5, 0.02, 120, 0.005},
+		{"testdata/1687_SCHWEITZER_0030.png", 5, 0.02, 90, 0.005},
+	}
+
+	for _, c := range cases {
+		t.Run(c.filename, func(t *testing.T) {
+			img, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			b := img.Bounds()
+
+			r := ContentArea(img, c.wsize, c.thresh, 30, c.vwsize, c.vthresh, 30)
+			if r.Empty() || r == b {
+				t.Fatalf("Unexpected content area %v for image %v\n", r, b)
+			}
+
+			wiped := Wipe(VWipe(img, c.vwsize, c.vthresh, 30), c.wsize, c.thresh, 30)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if !image.Pt(x, y).In(r) && wiped.GrayAt(x, y).Y != 255 {
+						t.Fatalf("Pixel %d,%d outside content area %v not wiped\n", x, y, r)
+					}
+				}
+			}
+
+			cropped := Crop(img, r)
+			if cropped.Bounds() != image.Rect(0, 0, r.Dx(), r.Dy()) {
+				t.Fatalf("Cropped image bounds %v do not match content area %v\n", cropped.Bounds(), r)
+			}
+			for y := 0; y < r.Dy(); y++ {
+				for x := 0; x < r.Dx(); x++ {
+					if cropped.GrayAt(x, y) != img.GrayAt(r.Min.X+x, r.Min.Y+y) {
+						t.Fatalf("Cropped pixel %d,%d differs from original\n", x, y)
+					}
+				}
+			}
+
+			margin := 20
+			withmargin := Crop(img, r.Inset(-margin))
+			want := r.Inset(-margin).Intersect(b)
+			if withmargin.Bounds().Dx() != want.Dx() || withmargin.Bounds().Dy() != want.Dy() {
+				t.Errorf("Cropped image with margin has bounds %v, expected size of %v\n", withmargin.Bounds(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"math"
+)
+
+// coarseSkewStep is the step in degrees between the angles which
+// are first checked by FindSkew, before refining around the best
+const coarseSkewStep = 0.5
+
+// minSkewPrecision is the finest precision in degrees which FindSkew
+// will search to, so that the search always finishes
+const minSkewPrecision = 0.01
+
+// FindSkew estimates the angle in degrees by which the text in a
+// binarised image is skewed, searching between -maxangle and
+// maxangle to within precision degrees. A positive angle means
+// that the lines of text slope down to the right, i.e. the page
+// has been rotated clockwise.
+//
+// For each candidate angle the black pixels are projected onto
+// rows along that angle, and the angle whose profile has the
+// greatest variance, as the rows line up with the lines of text,
+// is chosen. Only the bottom pixel of each vertical black run is
+// used, which sharpens the profile and saves time.
+//
+// A precision finer than minSkewPrecision, including a zero or
+// negative one, is treated as minSkewPrecision, and a negative
+// maxangle is treated as positive.
+func FindSkew(img *image.Gray, maxangle float64, precision float64) float64 {
+	if precision < minSkewPrecision {
+		precision = minSkewPrecision
+	}
+	maxangle = math.Abs(maxangle)
+
+	pts := runEnds(img)
+	if len(pts) == 0 {
+		return 0
+	}
+
+	step := coarseSkewStep
+	if precision > step {
+		step = precision
+	}
+	best := bestSkew(pts, img.Bounds(), -maxangle, maxangle, step)
+	if precision < step {
+		best = bestSkew(pts, img.Bounds(), best-step, best+step, precision)
+	}
+
+	return best
+}
+
+// runEnds returns the positions of every black pixel in an image
+// which does not have a black pixel directly below it
+func runEnds(img *image.Gray) []image.Point {
+	b := img.Bounds()
+	var pts []image.Point
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			if img.Pix[i] >= 128 {
+				continue
+			}
+			if y+1 < b.Max.Y && img.Pix[i+img.Stride] < 128 {
+				continue
+			}
+			pts = append(pts, image.Pt(x, y))
+		}
+	}
+	return pts
+}
+
+// bestSkew returns the angle from min to max, in steps of step,
+// at which the projection profile of pts has the highest variance
+func bestSkew(pts []image.Point, b image.Rectangle, min float64, max float64, step float64) float64 {
+	var best, bestscore float64
+	bestscore = -1
+	for a := min; a <= max+step/2; a += step {
+		score := skewScore(pts, b, a)
+		if score > bestscore || (score == bestscore && math.Abs(a) < math.Abs(best)) {
+			best, bestscore = a, score
+		}
+	}
+	return math.Round(best/step) * step
+}
+
+// skewScore returns the variance of the projection profile of pts
+// along an angle in degrees
+func skewScore(pts []image.Point, b image.Rectangle, angle float64) float64 {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	offset := int(math.Ceil(math.Abs(sin)*float64(b.Dx()))) + 1
+	profile := make([]int, b.Dy()+2*offset)
+
+	for _, p := range pts {
+		x, y := float64(p.X-b.Min.X), float64(p.Y-b.Min.Y)
+		row := int(math.Round(y*cos-x*sin)) + offset
+		if row >= 0 && row < len(profile) {
+			profile[row]++
+		}
+	}
+
+	var sum, sqsum float64
+	for _, v := range profile {
+		sum += float64(v)
+		sqsum += float64(v * v)
+	}
+	n := float64(len(profile))
+	m := sum / n
+	return sqsum/n - m*m
+}
+
+// rotatePix rotates the pixels of src clockwise by angle degrees
+// around the centre of b with bilinear interpolation, writing them
+// to dst. Both have channels bytes per pixel, and start at b.Min.
+// Areas which come from outside the source image are set to bg.
+func rotatePix(dst []uint8, dstStride int, src []uint8, srcStride int, b image.Rectangle, channels int, angle float64, bg uint8) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	cx := float64(b.Dx()-1) / 2
+	cy := float64(b.Dy()-1) / 2
+
+	at := func(x, y, c int) float64 {
+		if x < 0 || y < 0 || x >= b.Dx() || y >= b.Dy() {
+			return float64(bg)
+		}
+		return float64(src[y*srcStride+x*channels+c])
+	}
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := cos*dx + sin*dy + cx
+			sy := -sin*dx + cos*dy + cy
+			x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+			fx, fy := sx-float64(x0), sy-float64(y0)
+			for c := 0; c < channels; c++ {
+				top := at(x0, y0, c)*(1-fx) + at(x0+1, y0, c)*fx
+				bottom := at(x0, y0+1, c)*(1-fx) + at(x0+1, y0+1, c)*fx
+				dst[y*dstStride+x*channels+c] = uint8(math.Round(top*(1-fy) + bottom*fy))
+			}
+		}
+	}
+}
+
+// Rotate rotates an image clockwise by angle degrees around its
+// centre, keeping the same bounds. Any area which was outside the
+// original image is filled with white.
+func Rotate(img *image.Gray, angle float64) *image.Gray {
+	new := image.NewGray(img.Bounds())
+	rotatePix(new.Pix, new.Stride, img.Pix, img.Stride, img.Bounds(), 1, angle, 255)
+	return new
+}
+
+// RotateRGBA rotates an image clockwise by angle degrees around
+// its centre, keeping the same bounds. Any area which was outside
+// the original image is filled with white.
+func RotateRGBA(img *image.RGBA, angle float64) *image.RGBA {
+	new := image.NewRGBA(img.Bounds())
+	rotatePix(new.Pix, new.Stride, img.Pix, img.Stride, img.Bounds(), 4, angle, 255)
+	return new
+}
+
+// Deskew finds the skew of a binarised image with FindSkew and
+// rotates it to correct it, returning the straightened image and
+// the skew angle found. The rotated image is thresholded again so
+// that it stays binary. To straighten a greyscale or colour image,
+// use FindSkew on a binarised copy and then Rotate or RotateRGBA.
+func Deskew(img *image.Gray, maxangle float64, precision float64) (*image.Gray, float64) {
+	angle := FindSkew(img, maxangle, precision)
+	if angle == 0 {
+		return img, 0
+	}
+	new := Rotate(img, -angle)
+	for i, v := range new.Pix {
+		if v < 128 {
+			new.Pix[i] = 0
+		} else {
+			new.Pix[i] = 255
+		}
+	}
+	return new, angle
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFindSkew(t *testing.T) {
+	cases := []struct {
+		filename string
+		angle    float64
+		expected float64
+	}{
+		// pg2 is already skewed, with lines rising to the right
+		{"testdata/pg2.png", 0, -1.6},
+		{"testdata/pg2.png", 2, 0.4},
+		{"testdata/pg2.png", -1.3, -2.9},
+		{"testdata/1727_GREENE_0048.png", 0, 0},
+		{"testdata/1727_GREENE_0048.png", 3.5, 3.5},
+		{"testdata/1687_SCHWEITZER_0030.png", -0.7, -0.7},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%0.1f", c.filename, c.angle), func(t *testing.T) {
+			img, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			bin := Otsu(img)
+			if c.angle != 0 {
+				bin = Otsu(Rotate(bin, c.angle))
+			}
+			skew := FindSkew(bin, 5, 0.05)
+			if math.Abs(skew-c.expected) > 0.2 {
+				t.Errorf("Found skew %0.2f, expected %0.2f\n", skew, c.expected)
+			}
+
+			straight, found := Deskew(bin, 5, 0.05)
+			if found != skew {
+				t.Errorf("Deskew found skew %0.2f, FindSkew found %0.2f\n", found, skew)
+			}
+			if after := FindSkew(straight, 5, 0.05); math.Abs(after) > 0.2 {
+				t.Errorf("Skew after deskewing is %0.2f\n", after)
+			}
+		})
+	}
+}
+
+func TestFindSkewPrecision(t *testing.T) {
+	img, err := decode("testdata/pg2.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	for _, p := range []float64{0, -1} {
+		if skew := FindSkew(bin, 5, p); math.Abs(skew+1.6) > 0.2 {
+			t.Errorf("Found skew %0.2f with precision %v, expected -1.60\n", skew, p)
+		}
+	}
+	if skew := FindSkew(bin, -5, 0.05); math.Abs(skew+1.6) > 0.2 {
+		t.Errorf("Found skew %0.2f with a negative maximum angle, expected -1.60\n", skew)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	img, err := decode("testdata/pg2.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	if !imgsequal(Rotate(img, 0), img) {
+		t.Errorf("Rotating by 0 degrees changed the image\n")
+	}
+	r := Rotate(img, 10)
+	if r.Bounds() != img.Bounds() {
+		t.Errorf("Rotated image bounds %v differ from original %v\n", r.Bounds(), img.Bounds())
+	}
+	if r.GrayAt(0, 0).Y != 255 {
+		t.Errorf("Corner of rotated image not filled with white\n")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Despeckle removes specks of noise from a binarised image, by
+// turning any connected group of black pixels with an area of
+// maxsize pixels or less white. If white is true, any group of
+// white pixels with an area of maxsize pixels or less is also
+// turned black, which fills small holes in strokes. A good maxsize
+// is given by EstimateSizes.
+//
+// Black pixels are grouped with EightConnected connectivity, and
+// white pixels with FourConnected, so that a diagonal line of black
+// pixels separates the white pixels on either side of it.
+func Despeckle(img *image.Gray, maxsize int, white bool) *image.Gray {
+	b := img.Bounds()
+	new := image.NewGray(b)
+	draw.Draw(new, b, img, b.Min, draw.Src)
+
+	whitenSmall(new, maxsize, EightConnected)
+
+	if white {
+		inv := BinToBinaryInv(new)
+		whitenSmall(inv, maxsize, FourConnected)
+		new = BinToBinaryInv(inv)
+	}
+
+	return new
+}
+
+// whitenSmall turns any black component of img with an area of
+// maxsize pixels or less white
+func whitenSmall(img *image.Gray, maxsize int, connectivity int) {
+	labels, comps := Components(img, connectivity)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			l := labels.LabelAt(x, y)
+			if l != 0 && comps[l-1].Area <= maxsize {
+				img.Pix[i] = 255
+			}
+		}
+	}
+}
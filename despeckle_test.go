@@ -0,0 +1,81 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDespeckle(t *testing.T) {
+	img := whiteGray(image.Rect(0, 0, 30, 20))
+	black := color.Gray{0}
+	// a 2x2 speck
+	for y := 2; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			img.SetGray(x, y, black)
+		}
+	}
+	// a 10x10 block with a one pixel hole in it
+	for y := 5; y < 15; y++ {
+		for x := 10; x < 20; x++ {
+			img.SetGray(x, y, black)
+		}
+	}
+	img.SetGray(15, 10, color.Gray{255})
+
+	clean := Despeckle(img, 4, false)
+	if clean.GrayAt(2, 2).Y != 255 {
+		t.Errorf("Speck was not removed\n")
+	}
+	if clean.GrayAt(10, 5).Y != 0 {
+		t.Errorf("Block was removed\n")
+	}
+	if clean.GrayAt(15, 10).Y != 255 {
+		t.Errorf("Hole was filled when white was false\n")
+	}
+	if img.GrayAt(2, 2).Y != 0 {
+		t.Errorf("Original image was modified\n")
+	}
+
+	clean = Despeckle(img, 4, true)
+	if clean.GrayAt(15, 10).Y != 0 {
+		t.Errorf("Hole was not filled\n")
+	}
+	if clean.GrayAt(0, 0).Y != 255 || clean.GrayAt(2, 2).Y != 255 {
+		t.Errorf("Background was filled\n")
+	}
+
+	clean = Despeckle(img, 3, false)
+	if clean.GrayAt(2, 2).Y != 0 {
+		t.Errorf("Speck larger than maxsize was removed\n")
+	}
+}
+
+func TestDespecklePage(t *testing.T) {
+	img, err := decode("testdata/pg2.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	maxsize := EstimateSizes(img).Despeckle
+	clean := Despeckle(bin, maxsize, false)
+
+	_, before := Components(bin, EightConnected)
+	_, after := Components(clean, EightConnected)
+	var small int
+	for _, c := range before {
+		if c.Area <= maxsize {
+			small++
+		}
+	}
+	if small == 0 {
+		t.Fatalf("No specks found to remove\n")
+	}
+	if len(after) != len(before)-small {
+		t.Errorf("%d components after despeckling, expected %d\n", len(after), len(before)-small)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+)
+
+// FlattenBackground evens out uneven illumination in a greyscale
+// image, such as the shadow near the spine of a bound book or a
+// curling page, so that it isn't picked up as text by binarization.
+//
+// The background is estimated by taking the lightest pixel in a
+// wsize square window around each pixel, which removes the text
+// as long as the window is larger than the characters, and then
+// smoothing that with a box blur of the same size. Each pixel is
+// then divided by the background, so that the background becomes
+// white and the text keeps its contrast with it. A good wsize is
+// given by EstimateSizes. A wsize below 1 is treated as 1.
+func FlattenBackground(img image.Image, wsize int) *image.Gray {
+	if wsize < 1 {
+		wsize = 1
+	}
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+
+	bg := boxBlur(maxFilter(gray, wsize), wsize)
+
+	new := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := gray.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			v := 255
+			if bg.Pix[i] > 0 {
+				v = int(gray.Pix[i]) * 255 / int(bg.Pix[i])
+			}
+			if v > 255 {
+				v = 255
+			}
+			new.Pix[i] = uint8(v)
+		}
+	}
+
+	return new
+}
+
+// maxFilter sets each pixel of an image to the lightest value in
+// the wsize square window around it. It is done separably, along
+// rows and then columns.
+func maxFilter(img *image.Gray, wsize int) *image.Gray {
+	return transposeGray(filterRows(transposeGray(filterRows(img, wsize, maxRow)), wsize, maxRow))
+}
+
+// boxBlur sets each pixel of an image to the mean of the wsize
+// square window around it. It is done separably, along rows and
+// then columns.
+func boxBlur(img *image.Gray, wsize int) *image.Gray {
+	return transposeGray(filterRows(transposeGray(filterRows(img, wsize, meanRow)), wsize, meanRow))
+}
+
+// filterRows applies a one dimensional filter to each row of an
+// image
+func filterRows(img *image.Gray, wsize int, f func(dst, src []uint8, wsize int)) *image.Gray {
+	b := img.Bounds()
+	new := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		src := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		dst := new.Pix[new.PixOffset(b.Min.X, y):new.PixOffset(b.Max.X, y)]
+		f(dst, src, wsize)
+	}
+	return new
+}
+
+// maxRow sets each value of dst to the maximum of the wsize values
+// of src centred on it, using a monotonic queue of the positions of
+// the values which could still be the maximum
+func maxRow(dst, src []uint8, wsize int) {
+	step := wsize / 2
+	queue := make([]int, 0, wsize+1)
+	next := 0
+	for x := range dst {
+		for ; next < len(src) && next <= x+step; next++ {
+			for len(queue) > 0 && src[queue[len(queue)-1]] <= src[next] {
+				queue = queue[:len(queue)-1]
+			}
+			queue = append(queue, next)
+		}
+		for queue[0] < x-step {
+			queue = queue[1:]
+		}
+		dst[x] = src[queue[0]]
+	}
+}
+
+// meanRow sets each value of dst to the mean of the wsize values of
+// src centred on it, or as many of them as are within src, using a
+// running sum
+func meanRow(dst, src []uint8, wsize int) {
+	step := wsize / 2
+	var sum, n int
+	for x := 0; x < step && x < len(src); x++ {
+		sum += int(src[x])
+		n++
+	}
+	for x := range dst {
+		if in := x + step; in < len(src) {
+			sum += int(src[in])
+			n++
+		}
+		if out := x - step - 1; out >= 0 {
+			sum -= int(src[out])
+			n--
+		}
+		dst[x] = uint8((sum + n/2) / n)
+	}
+}
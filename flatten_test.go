@@ -0,0 +1,56 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFlattenBackground(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	b := img.Bounds()
+	wsize := EstimateSizes(img).Flatten
+
+	// darken the right of the page, getting darker towards the edge,
+	// like the shadow of a gutter
+	shadowed := image.NewGray(b)
+	shadowstart := b.Max.X - 400
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := int(img.GrayAt(x, y).Y)
+			if x > shadowstart {
+				v = v * (1000 - (x-shadowstart)*6/4) / 1000
+			}
+			shadowed.Pix[shadowed.PixOffset(x, y)] = uint8(v)
+		}
+	}
+	shadow := image.Rect(shadowstart, b.Min.Y, b.Max.X, b.Max.Y)
+
+	orig := blackProportion(Otsu(img), shadow)
+	before := blackProportion(Otsu(shadowed), shadow)
+	after := blackProportion(Otsu(FlattenBackground(shadowed, wsize)), shadow)
+
+	if before < orig*4 {
+		t.Fatalf("Shadow was not dark enough to test, %0.3f black compared to %0.3f\n", before, orig)
+	}
+	if after > orig*1.5+0.01 {
+		t.Errorf("Shadow was %0.3f black after flattening, compared to %0.3f originally\n", after, orig)
+	}
+
+	flat := FlattenBackground(img, wsize)
+	if p := blackProportion(Otsu(flat), b); p > blackProportion(Otsu(img), b)*1.5+0.01 {
+		t.Errorf("Flattening an evenly lit page made it %0.3f black\n", p)
+	}
+
+	for _, w := range []int{0, -5} {
+		if flat := FlattenBackground(img, w); flat.Bounds() != b {
+			t.Errorf("Flattening with a window size of %d gave bounds %v, expected %v\n", w, flat.Bounds(), b)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// whiteGray returns a white image of the given bounds
+func whiteGray(r image.Rectangle) *image.Gray {
+	img := image.NewGray(r)
+	draw.Draw(img, r, &image.Uniform{color.Gray{255}}, image.Point{}, draw.Src)
+	return img
+}
+
+// fillRect draws a black rectangle
+func fillRect(img *image.Gray, r image.Rectangle) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetGray(x, y, color.Gray{0})
+		}
+	}
+}
+
+// fillCircle draws a black circle of radius r centred on cx, cy
+func fillCircle(img *image.Gray, cx, cy, r int) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+				img.SetGray(x, y, color.Gray{0})
+			}
+		}
+	}
+}
+
+// fillGray fills an area of an image with a grey value
+func fillGray(img *image.Gray, r image.Rectangle, v uint8) {
+	draw.Draw(img, r, &image.Uniform{color.Gray{v}}, image.Point{}, draw.Src)
+}
+
+// blackProportion returns the proportion of pixels in the area r of
+// a binarised image which are black
+func blackProportion(img *image.Gray, r image.Rectangle) float64 {
+	var black int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.GrayAt(x, y).Y < 128 {
+				black++
+			}
+		}
+	}
+	return float64(black) / float64(r.Dx()*r.Dy())
+}
+
+// grayAll reports whether every pixel in an area of an image has a
+// grey value
+func grayAll(img *image.Gray, r image.Rectangle, v uint8) bool {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.GrayAt(x, y).Y != v {
+				return false
+			}
+		}
+	}
+	return true
+}
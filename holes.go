@@ -0,0 +1,140 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// HoleKind is the type of a Hole.
+type HoleKind int
+
+// The kinds of Hole which FindHoles detects.
+const (
+	RoundHole  HoleKind = iota // A round punch hole
+	SquareHole                 // A square or rectangular punch hole
+	Staple                     // A binding staple, or its holes
+)
+
+// Hole is a punch hole or staple found by FindHoles.
+type Hole struct {
+	Bounds image.Rectangle
+	Kind   HoleKind
+}
+
+// HoleParams are the physical sizes used by FindHoles, in mm, along
+// with the resolution of the image needed to convert them to
+// pixels.
+type HoleParams struct {
+	DPI       float64 // Resolution of the image in dots per inch
+	MinHole   float64 // Smallest width of a punch hole
+	MaxHole   float64 // Largest width of a punch hole
+	MinStaple float64 // Shortest length of a staple
+	MaxStaple float64 // Longest length of a staple
+	Edge      float64 // Furthest distance of the centre of a hole from the edge of the page
+}
+
+// DefaultHoleParams returns HoleParams for an image of the given
+// resolution, with sizes which suit common office punches and
+// staples.
+func DefaultHoleParams(dpi float64) HoleParams {
+	return HoleParams{
+		DPI:       dpi,
+		MinHole:   4,
+		MaxHole:   10,
+		MinStaple: 8,
+		MaxStaple: 20,
+		Edge:      25,
+	}
+}
+
+// Shape statistics used to classify components by FindHoles. The
+// fill is the proportion of the bounding box which is black, which
+// is about pi/4 for a circle.
+const (
+	maxHoleAspect   = 1.5
+	minRoundFill    = 0.65
+	minSquareFill   = 0.9
+	minStapleAspect = 4
+	minStapleFill   = 0.8
+)
+
+// mmToPixels converts a length in mm to pixels at a resolution in
+// dots per inch
+func mmToPixels(mm float64, dpi float64) float64 {
+	return mm / 25.4 * dpi
+}
+
+// FindHoles finds punch holes and binding staples in a binarised
+// image, which show as solid black blobs near the edge of the page.
+// Each connected component is classified from its size, the aspect
+// ratio of its bounding box and how much of the bounding box it
+// fills. Roughly square components of the size of a punch hole are
+// taken as round or square holes, depending on their fill, and long
+// thin solid components of the length of a staple are taken as
+// staples. Only components whose centre is within p.Edge of the
+// edge of the image are considered, so that text and illustrations
+// are left alone.
+func FindHoles(img *image.Gray, p HoleParams) []Hole {
+	b := img.Bounds()
+	minhole := mmToPixels(p.MinHole, p.DPI)
+	maxhole := mmToPixels(p.MaxHole, p.DPI)
+	minstaple := mmToPixels(p.MinStaple, p.DPI)
+	maxstaple := mmToPixels(p.MaxStaple, p.DPI)
+	edge := mmToPixels(p.Edge, p.DPI)
+
+	_, comps := Components(img, EightConnected)
+
+	var holes []Hole
+	for _, c := range comps {
+		dist := math.Min(
+			math.Min(c.CentroidX-float64(b.Min.X), float64(b.Max.X)-c.CentroidX),
+			math.Min(c.CentroidY-float64(b.Min.Y), float64(b.Max.Y)-c.CentroidY))
+		if dist > edge {
+			continue
+		}
+
+		long := float64(c.Bounds.Dx())
+		short := float64(c.Bounds.Dy())
+		if short > long {
+			long, short = short, long
+		}
+		aspect := long / short
+		fill := float64(c.Area) / float64(c.Bounds.Dx()*c.Bounds.Dy())
+
+		switch {
+		case aspect <= maxHoleAspect && long >= minhole && long <= maxhole && fill >= minSquareFill:
+			holes = append(holes, Hole{c.Bounds, SquareHole})
+		case aspect <= maxHoleAspect && long >= minhole && long <= maxhole && fill >= minRoundFill:
+			holes = append(holes, Hole{c.Bounds, RoundHole})
+		case aspect >= minStapleAspect && long >= minstaple && long <= maxstaple && fill >= minStapleFill:
+			holes = append(holes, Hole{c.Bounds, Staple})
+		}
+	}
+
+	return holes
+}
+
+// RemoveHoles finds punch holes and staples with FindHoles, and
+// turns their bounding boxes white. It returns the cleaned image
+// and the holes which were found.
+func RemoveHoles(img *image.Gray, p HoleParams) (*image.Gray, []Hole) {
+	b := img.Bounds()
+	new := image.NewGray(b)
+	draw.Draw(new, b, img, b.Min, draw.Src)
+
+	holes := FindHoles(img, p)
+	for _, h := range holes {
+		for y := h.Bounds.Min.Y; y < h.Bounds.Max.Y; y++ {
+			for x := h.Bounds.Min.X; x < h.Bounds.Max.X; x++ {
+				new.Pix[new.PixOffset(x, y)] = 255
+			}
+		}
+	}
+
+	return new, holes
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFindHoles(t *testing.T) {
+	cases := []string{
+		"testdata/1727_GREENE_0048.png",
+		"testdata/1687_SCHWEITZER_0030.png",
+		"testdata/pg2.png",
+	}
+	p := DefaultHoleParams(300)
+
+	for _, c := range cases {
+		img, err := decode(c)
+		if err != nil {
+			t.Fatalf("Could not open file %s: %v\n", c, err)
+		}
+		if holes := FindHoles(Otsu(img), p); len(holes) != 0 {
+			t.Errorf("Found holes in %s, which has none: %v\n", c, holes)
+		}
+	}
+
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	b := bin.Bounds()
+	// a 6mm punch hole near the left edge, a 7mm square hole near
+	// the right edge, a 12mm staple near the top, and a 6mm circle
+	// away from the edges
+	fillCircle(bin, b.Min.X+60, b.Min.Y+b.Dy()/3, 35)
+	square := image.Rect(b.Max.X-120, b.Max.Y-200, b.Max.X-37, b.Max.Y-117)
+	fillRect(bin, square)
+	staple := image.Rect(b.Min.X+200, b.Min.Y+40, b.Min.X+342, b.Min.Y+49)
+	fillRect(bin, staple)
+	fillCircle(bin, b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2, 35)
+
+	clean, holes := RemoveHoles(bin, p)
+	if len(holes) != 3 {
+		t.Fatalf("Found %d holes, expected 3: %v\n", len(holes), holes)
+	}
+	kinds := make(map[HoleKind]image.Rectangle)
+	for _, h := range holes {
+		kinds[h.Kind] = h.Bounds
+	}
+	if kinds[SquareHole] != square {
+		t.Errorf("Square hole found at %v, expected %v\n", kinds[SquareHole], square)
+	}
+	if kinds[Staple] != staple {
+		t.Errorf("Staple found at %v, expected %v\n", kinds[Staple], staple)
+	}
+	if _, ok := kinds[RoundHole]; !ok {
+		t.Errorf("Round hole not found\n")
+	}
+	if clean.GrayAt(b.Min.X+60, b.Min.Y+b.Dy()/3).Y != 255 || clean.GrayAt(staple.Min.X, staple.Min.Y).Y != 255 {
+		t.Errorf("Holes were not removed\n")
+	}
+	if clean.GrayAt(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2).Y != 0 {
+		t.Errorf("Circle away from the edge was removed\n")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+	"sort"
+
+	"rescribe.xyz/integral"
+)
+
+// FindLines finds the lines of text within the area r of a
+// binarised image, such as a column found by FindColumns, and
+// returns their bounding rectangles from top to bottom.
+//
+// As in VWipe, the image is turned sideways so that ProportionSlice
+// can measure each row, and any row with a proportion of black
+// pixels at or below thresh is treated as a gap between lines, so
+// gaps of any size are found. Blocks which are much taller than
+// lineheight, because ascenders and descenders touch, are split at
+// the emptiest rows near where the line boundaries are expected.
+// Blocks much shorter than lineheight, such as accents separated
+// from their line, are merged into the nearest line.
+//
+// lineheight is the distance between the baselines of consecutive
+// lines. If it is 0 it is measured from the image.
+func FindLines(img *image.Gray, r image.Rectangle, lineheight int, thresh float64) []image.Rectangle {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return nil
+	}
+	sub := img.SubImage(r).(*image.Gray)
+
+	rotimg := sideways(sub)
+	b := rotimg.Bounds()
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, rotimg, b.Min, draw.Src)
+
+	profile := make([]float64, r.Dy())
+	for y := range profile {
+		slice := image.Rect(b.Min.X+y, b.Min.Y, b.Min.X+y+1, b.Max.Y)
+		profile[y] = proportionRect(*intImg, slice)
+	}
+
+	// find blocks of rows separated by gaps
+	var blocks [][2]int
+	for y := 0; y < len(profile); {
+		if profile[y] <= thresh {
+			y++
+			continue
+		}
+		start := y
+		for y < len(profile) && profile[y] > thresh {
+			y++
+		}
+		blocks = append(blocks, [2]int{start, y})
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	if lineheight <= 0 {
+		lineheight = lineHeight(sub)
+	}
+	if lineheight <= 0 {
+		lineheight = medianBlockHeight(blocks)
+	}
+
+	blocks = splitBlocks(blocks, profile, lineheight)
+	blocks = mergeBlocks(blocks, lineheight/4)
+
+	var lines []image.Rectangle
+	for _, bl := range blocks {
+		line := image.Rect(r.Min.X, r.Min.Y+bl[0], r.Max.X, r.Min.Y+bl[1])
+		lines = append(lines, blackBounds(img, line))
+	}
+
+	return lines
+}
+
+// medianBlockHeight returns the median height of a list of blocks
+func medianBlockHeight(blocks [][2]int) int {
+	var heights []int
+	for _, bl := range blocks {
+		heights = append(heights, bl[1]-bl[0])
+	}
+	sort.Ints(heights)
+	return heights[len(heights)/2]
+}
+
+// splitBlocks splits any block more than 1.5 times lineheight tall
+// into as many lines as would fit in it, cutting at the row with
+// the lowest value in profile within half a line of where each cut
+// would be expected
+func splitBlocks(blocks [][2]int, profile []float64, lineheight int) [][2]int {
+	var split [][2]int
+	for _, bl := range blocks {
+		h := bl[1] - bl[0]
+		if h*2 <= lineheight*3 {
+			split = append(split, bl)
+			continue
+		}
+		n := (h + lineheight/2) / lineheight
+		start := bl[0]
+		for i := 1; i < n; i++ {
+			expected := bl[0] + h*i/n
+			cut := expected
+			for y := expected - lineheight/2; y <= expected+lineheight/2; y++ {
+				if y <= start || y >= bl[1] {
+					continue
+				}
+				if profile[y] < profile[cut] {
+					cut = y
+				}
+			}
+			split = append(split, [2]int{start, cut})
+			start = cut
+		}
+		split = append(split, [2]int{start, bl[1]})
+	}
+	return split
+}
+
+// mergeBlocks merges any block shorter than min into whichever
+// neighbouring block is closest
+func mergeBlocks(blocks [][2]int, min int) [][2]int {
+	for i := 0; i < len(blocks); {
+		if blocks[i][1]-blocks[i][0] >= min || len(blocks) == 1 {
+			i++
+			continue
+		}
+		var into int
+		switch {
+		case i == 0:
+			into = 1
+		case i == len(blocks)-1:
+			into = i - 1
+		case blocks[i][0]-blocks[i-1][1] <= blocks[i+1][0]-blocks[i][1]:
+			into = i - 1
+		default:
+			into = i + 1
+		}
+		if blocks[i][0] < blocks[into][0] {
+			blocks[into][0] = blocks[i][0]
+		}
+		if blocks[i][1] > blocks[into][1] {
+			blocks[into][1] = blocks[i][1]
+		}
+		blocks = append(blocks[:i], blocks[i+1:]...)
+		if into < i {
+			i = into
+		}
+	}
+	return blocks
+}
+
+// blackBounds returns the smallest rectangle within r which
+// contains all of the black pixels in r, or r if there are none
+func blackBounds(img *image.Gray, r image.Rectangle) image.Rectangle {
+	minx, maxx := r.Max.X, r.Min.X
+	miny, maxy := r.Max.Y, r.Min.Y
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, i = x+1, i+1 {
+			if img.Pix[i] >= 128 {
+				continue
+			}
+			if x < minx {
+				minx = x
+			}
+			if x >= maxx {
+				maxx = x + 1
+			}
+			if y < miny {
+				miny = y
+			}
+			if y >= maxy {
+				maxy = y + 1
+			}
+		}
+	}
+	if minx >= maxx {
+		return r
+	}
+	return image.Rect(minx, miny, maxx, maxy)
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFindLines(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	// the two paragraphs between the figures
+	r := image.Rect(160, 750, 1290, 1325)
+	expected := 17
+
+	lines := FindLines(bin, r, 0, 0.01)
+	if len(lines) != expected {
+		t.Fatalf("Found %d lines, expected %d\n", len(lines), expected)
+	}
+	for i, l := range lines {
+		if !l.In(r) {
+			t.Errorf("Line %v outside area %v\n", l, r)
+		}
+		if i > 0 && l.Min.Y < lines[i-1].Max.Y {
+			t.Errorf("Line %v overlaps previous line %v\n", l, lines[i-1])
+		}
+	}
+
+	// join every line with a thick bar, so that there are no gaps
+	// and the lines have to be split
+	joined := Otsu(img)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Max.X - 25; x < r.Max.X; x++ {
+			joined.SetGray(x, y, color.Gray{0})
+		}
+	}
+	for _, lineheight := range []int{0, 33} {
+		split := FindLines(joined, r, lineheight, 0.01)
+		if len(split) != expected {
+			t.Fatalf("Found %d joined lines with line height %d, expected %d\n", len(split), lineheight, expected)
+		}
+		for i := range split {
+			mid := (split[i].Min.Y + split[i].Max.Y) / 2
+			if mid < lines[i].Min.Y || mid > lines[i].Max.Y {
+				t.Errorf("Joined line %v does not match line %v\n", split[i], lines[i])
+			}
+		}
+	}
+}
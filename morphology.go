@@ -0,0 +1,195 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+)
+
+// seRun is a horizontal run of a structuring element, covering the
+// offsets x0 to x1 inclusive on row dy
+type seRun struct {
+	dy, x0, x1 int
+}
+
+// StructuringElement is the shape used by the binary morphology
+// operations, as a set of offsets from its origin. It is stored as
+// horizontal runs, so that the operations only need to check one
+// value per run rather than one per pixel.
+type StructuringElement struct {
+	runs []seRun
+	// rect is set for rectangular elements, which are processed
+	// separably
+	rect bool
+}
+
+// RectElement returns a rectangular StructuringElement of w by h
+// pixels, with its origin in the centre.
+func RectElement(w int, h int) StructuringElement {
+	var runs []seRun
+	for dy := -h / 2; dy < h-h/2; dy++ {
+		runs = append(runs, seRun{dy, -w / 2, w - 1 - w/2})
+	}
+	return StructuringElement{runs: runs, rect: true}
+}
+
+// NewElement returns a StructuringElement of any shape, made from
+// the true values of mask, which is indexed as mask[y][x], with its
+// origin at the position origin in the mask.
+func NewElement(mask [][]bool, origin image.Point) StructuringElement {
+	var runs []seRun
+	for y, row := range mask {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			runs = append(runs, seRun{y - origin.Y, start - origin.X, x - 1 - origin.X})
+		}
+	}
+	return StructuringElement{runs: runs}
+}
+
+// reflect returns the element reflected through its origin
+func (se StructuringElement) reflect() StructuringElement {
+	new := StructuringElement{rect: se.rect}
+	for _, r := range se.runs {
+		new.runs = append(new.runs, seRun{-r.dy, -r.x1, -r.x0})
+	}
+	return new
+}
+
+// Erode erodes the black areas of a binarised image with a
+// structuring element, so that a pixel is only black if every
+// pixel covered by the element when its origin is placed there is
+// black. Pixels outside the image count as white.
+//
+// Rectangular elements are applied separably, as a row and then a
+// column, so the time taken does not depend on their size.
+func Erode(img *image.Gray, se StructuringElement) *image.Gray {
+	return erode(img, se, false)
+}
+
+// Dilate dilates the black areas of a binarised image with a
+// structuring element, so that a pixel is black if the element,
+// reflected and placed at that pixel, covers any black pixel.
+func Dilate(img *image.Gray, se StructuringElement) *image.Gray {
+	return BinToBinaryInv(erode(BinToBinaryInv(img), se.reflect(), true))
+}
+
+// Open erodes and then dilates an image, which removes black areas
+// which the structuring element does not fit inside.
+func Open(img *image.Gray, se StructuringElement) *image.Gray {
+	return Dilate(Erode(img, se), se)
+}
+
+// Close dilates and then erodes an image, which fills white gaps
+// which the structuring element does not fit inside, such as breaks
+// in strokes.
+func Close(img *image.Gray, se StructuringElement) *image.Gray {
+	return Erode(Dilate(img, se), se)
+}
+
+// erode implements Erode, with pixels outside the image counting as
+// black if outsideBlack is true
+func erode(img *image.Gray, se StructuringElement, outsideBlack bool) *image.Gray {
+	if se.rect && len(se.runs) > 0 {
+		first, last := se.runs[0], se.runs[len(se.runs)-1]
+		if first.dy > last.dy {
+			first, last = last, first
+		}
+		row := StructuringElement{runs: []seRun{{0, first.x0, first.x1}}}
+		col := StructuringElement{runs: []seRun{{0, first.dy, last.dy}}}
+		horiz := erodeRuns(img, row, outsideBlack)
+		return transposeGray(erodeRuns(transposeGray(horiz), col, outsideBlack))
+	}
+	return erodeRuns(img, se, outsideBlack)
+}
+
+// erodeRuns erodes an image with a structuring element, using the
+// length of the run of black pixels starting at each pixel to check
+// each run of the element at once
+func erodeRuns(img *image.Gray, se StructuringElement, outsideBlack bool) *image.Gray {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// runs[y*w+x] is the number of consecutive black pixels from
+	// x, y to the right
+	runs := make([]int32, w*h)
+	for y := 0; y < h; y++ {
+		var run int32
+		i := img.PixOffset(b.Max.X-1, b.Min.Y+y)
+		for x := w - 1; x >= 0; x, i = x-1, i-1 {
+			if img.Pix[i] < 128 {
+				run++
+			} else {
+				run = 0
+			}
+			runs[y*w+x] = run
+		}
+	}
+
+	new := image.NewGray(b)
+	for y := 0; y < h; y++ {
+		i := new.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x, i = x+1, i+1 {
+			black := true
+			for _, r := range se.runs {
+				yy := y + r.dy
+				if yy < 0 || yy >= h {
+					if outsideBlack {
+						continue
+					}
+					black = false
+					break
+				}
+				x0, x1 := x+r.x0, x+r.x1
+				if x0 < 0 || x1 >= w {
+					if !outsideBlack {
+						black = false
+						break
+					}
+					if x0 < 0 {
+						x0 = 0
+					}
+					if x1 >= w {
+						x1 = w - 1
+					}
+					if x0 > x1 {
+						continue
+					}
+				}
+				if int(runs[yy*w+x0]) <= x1-x0 {
+					black = false
+					break
+				}
+			}
+			if black {
+				new.Pix[i] = 0
+			} else {
+				new.Pix[i] = 255
+			}
+		}
+	}
+
+	return new
+}
+
+// transposeGray swaps the x and y axes of an image
+func transposeGray(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	new := image.NewGray(image.Rect(b.Min.Y, b.Min.X, b.Max.Y, b.Max.X))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			new.Pix[new.PixOffset(y, x)] = img.Pix[i]
+		}
+	}
+	return new
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// slowMorph applies a structuring element, given as a list of
+// offsets, to an image one pixel at a time, to check the fast
+// implementations against
+func slowMorph(img *image.Gray, offsets []image.Point, dilate bool) *image.Gray {
+	b := img.Bounds()
+	new := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			black := !dilate
+			for _, o := range offsets {
+				var p image.Point
+				if dilate {
+					p = image.Pt(x-o.X, y-o.Y)
+				} else {
+					p = image.Pt(x+o.X, y+o.Y)
+				}
+				pblack := p.In(b) && img.GrayAt(p.X, p.Y).Y < 128
+				if dilate && pblack {
+					black = true
+				}
+				if !dilate && !pblack {
+					black = false
+				}
+			}
+			if black {
+				new.SetGray(x, y, color.Gray{0})
+			} else {
+				new.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+	return new
+}
+
+func TestMorphology(t *testing.T) {
+	img, err := decode("testdata/pg2.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img).SubImage(image.Rect(400, 150, 800, 350)).(*image.Gray)
+
+	var rectOffsets []image.Point
+	for dy := -2; dy <= 1; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			rectOffsets = append(rectOffsets, image.Pt(dx, dy))
+		}
+	}
+	mask := [][]bool{
+		{false, true, false},
+		{true, true, true},
+		{false, true, true},
+		{false, false, true},
+	}
+	var maskOffsets []image.Point
+	for y, row := range mask {
+		for x, v := range row {
+			if v {
+				maskOffsets = append(maskOffsets, image.Pt(x-1, y-1))
+			}
+		}
+	}
+
+	cases := []struct {
+		name    string
+		se      StructuringElement
+		offsets []image.Point
+	}{
+		{"rect", RectElement(5, 4), rectOffsets},
+		{"rectmask", NewElement([][]bool{{true, true, true, true, true}, {true, true, true, true, true}, {true, true, true, true, true}, {true, true, true, true, true}}, image.Pt(2, 2)), rectOffsets},
+		{"custom", NewElement(mask, image.Pt(1, 1)), maskOffsets},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if !imgsequal(Erode(bin, c.se), slowMorph(bin, c.offsets, false)) {
+				t.Errorf("Erode differs from expected\n")
+			}
+			if !imgsequal(Dilate(bin, c.se), slowMorph(bin, c.offsets, true)) {
+				t.Errorf("Dilate differs from expected\n")
+			}
+		})
+	}
+}
+
+func TestOpenClose(t *testing.T) {
+	img := whiteGray(image.Rect(0, 0, 30, 20))
+	black := color.Gray{0}
+	// a speck, and a bar with a one pixel break in it
+	img.SetGray(2, 2, black)
+	for y := 8; y < 12; y++ {
+		for x := 5; x < 25; x++ {
+			if x != 15 {
+				img.SetGray(x, y, black)
+			}
+		}
+	}
+	se := RectElement(3, 3)
+
+	opened := Open(img, se)
+	if opened.GrayAt(2, 2).Y != 255 {
+		t.Errorf("Speck survived opening\n")
+	}
+	if opened.GrayAt(10, 10).Y != 0 {
+		t.Errorf("Bar did not survive opening\n")
+	}
+
+	closed := Close(img, se)
+	if closed.GrayAt(15, 10).Y != 0 {
+		t.Errorf("Break in bar not closed\n")
+	}
+	if closed.GrayAt(2, 2).Y != 0 || closed.GrayAt(0, 0).Y != 255 {
+		t.Errorf("Closing changed the area around the speck\n")
+	}
+}
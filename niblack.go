@@ -0,0 +1,82 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"rescribe.xyz/integral"
+)
+
+func init() {
+	RegisterBinarizer("niblack", func(p BinarizerParams) Binarizer {
+		return NiblackBinarizer{K: p.K, Wsize: p.Wsize}
+	})
+}
+
+// NiblackBinarizer is a Binarizer which uses the Integral Image
+// implementation of Niblack's algorithm.
+type NiblackBinarizer struct {
+	K     float64
+	Wsize int
+}
+
+// Binarize binarizes an image with IntegralNiblack.
+func (n NiblackBinarizer) Binarize(img image.Image) *image.Gray {
+	return IntegralNiblack(img, n.K, n.Wsize)
+}
+
+// BinarizeIntegral binarizes an image with PreCalcedNiblack.
+func (n NiblackBinarizer) BinarizeIntegral(intImg integral.Image, intSqImg integral.SqImage, img image.Image) *image.Gray {
+	return PreCalcedNiblack(intImg, intSqImg, img, n.K, n.Wsize)
+}
+
+// DefaultK returns -0.2, as Niblack needs a negative k.
+func (n NiblackBinarizer) DefaultK() float64 {
+	return -0.2
+}
+
+// IntegralNiblack implements Niblack's algorithm for text binarization
+// using Integral Images, see the book "An Introduction to Digital Image
+// Processing" (1986). Unlike Sauvola, ksize should usually be negative;
+// -0.2 is a good starting point.
+func IntegralNiblack(img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, img, b.Min, draw.Src)
+	intSqImg := integral.NewSqImage(b)
+	draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+
+	return PreCalcedNiblack(*intImg, *intSqImg, img, ksize, windowsize)
+}
+
+// PreCalcedNiblack Implements Niblack's algorithm using precalculated Integral Images
+func PreCalcedNiblack(intImg integral.Image, intSqImg integral.SqImage, img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	new := image.NewGray(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r := centeredRectangle(x, y, windowsize)
+			m, dev := integralMeanStdDev(intImg, intSqImg, r)
+			threshold := m + ksize*dev
+			// compare as floats, as a large ksize can take the
+			// threshold outside of the range of a uint8
+			if float64(gray.GrayAt(x, y).Y) < math.Round(threshold) {
+				new.SetGray(x, y, color.Gray{0})
+			} else {
+				new.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+
+	return new
+}
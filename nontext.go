@@ -0,0 +1,143 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"rescribe.xyz/integral"
+)
+
+// Limits used by FindNonText to decide what isn't text.
+const (
+	// maxTextHeight and maxTextWidth are the largest size of a
+	// connected component which can be text, in line heights
+	maxTextHeight = 2
+	maxTextWidth  = 4
+	// maxTextDensity is the largest proportion of black pixels a
+	// cell of text can have
+	maxTextDensity = 0.45
+	// maxTinySize is the number of tiny fragments, such as the
+	// broken hatching of an engraving, which fit into a line height
+	maxTinySize = 8
+	// minTinyCount and maxTinyProportion are the fewest components
+	// and the largest proportion of them which can be tiny fragments
+	// in a cell of text
+	minTinyCount      = 6
+	maxTinyProportion = 0.5
+)
+
+// FindNonText finds the areas of a binarised image which are not
+// text, such as woodcuts, engravings and diagrams, and returns a
+// mask of them, which is black where there is no text and white
+// elsewhere.
+//
+// Three things are taken as signs of non-text. Firstly connected
+// components which are much larger than a character, more than
+// maxTextHeight line heights tall or maxTextWidth line heights
+// wide, as the lines of an illustration usually join up into large
+// components. The rest of the image is split into square cells one
+// line height wide, and a cell is marked if it is denser than text
+// can be, with a proportion of black pixels above maxTextDensity,
+// or if most of the components centred in it are tiny fragments,
+// as the fine hatching of an engraving is broken up by
+// binarization. The marked areas are grown by half a line height,
+// to cover the loose fragments around the edges of an illustration.
+//
+// lineheight is the distance between the baselines of consecutive
+// lines. If it is 0 it is measured from the image.
+func FindNonText(img *image.Gray, lineheight int) *image.Gray {
+	b := img.Bounds()
+	if lineheight <= 0 {
+		lineheight = lineHeight(img)
+	}
+	if lineheight <= 0 {
+		lineheight = b.Dx() / 40
+	}
+	if lineheight < 1 {
+		lineheight = 1
+	}
+
+	mask := image.NewGray(b)
+	draw.Draw(mask, b, &image.Uniform{color.Gray{255}}, image.Point{}, draw.Src)
+	black := &image.Uniform{color.Gray{0}}
+
+	// count and tiny hold the number of components centred in each
+	// cell, and how many of them are tiny fragments
+	cw := (b.Dx() + lineheight - 1) / lineheight
+	ch := (b.Dy() + lineheight - 1) / lineheight
+	count := make([]int, cw*ch)
+	tiny := make([]int, cw*ch)
+
+	_, comps := Components(img, EightConnected)
+	for _, c := range comps {
+		if c.Bounds.Dy() > lineheight*maxTextHeight || c.Bounds.Dx() > lineheight*maxTextWidth {
+			draw.Draw(mask, c.Bounds, black, image.Point{}, draw.Src)
+			continue
+		}
+		cx := (int(c.CentroidX) - b.Min.X) / lineheight
+		cy := (int(c.CentroidY) - b.Min.Y) / lineheight
+		count[cy*cw+cx]++
+		if c.Bounds.Dx()*maxTinySize <= lineheight && c.Bounds.Dy()*maxTinySize <= lineheight {
+			tiny[cy*cw+cx]++
+		}
+	}
+
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, img, b.Min, draw.Src)
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			x, y := b.Min.X+cx*lineheight, b.Min.Y+cy*lineheight
+			cell := image.Rect(x, y, x+lineheight, y+lineheight).Intersect(b)
+			n := count[cy*cw+cx]
+			if n >= minTinyCount && float64(tiny[cy*cw+cx]) > float64(n)*maxTinyProportion {
+				draw.Draw(mask, cell, black, image.Point{}, draw.Src)
+				continue
+			}
+
+			// 1 << 16 - 1 as we're using Gray16, so 1 << 16 - 1 = white
+			white := float64(intImg.Sum(cell)) / float64(1<<16-1)
+			density := 1 - white/float64(cell.Dx()*cell.Dy())
+			if density > maxTextDensity {
+				draw.Draw(mask, cell, black, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return Dilate(mask, RectElement(lineheight, lineheight))
+}
+
+// KeepNonText replaces the areas of a binarised image which are
+// marked as non-text in mask, as returned by FindNonText, with the
+// same areas of the original image in greyscale, so that
+// illustrations are kept rather than binarised into noise.
+//
+// It should be used before wiping or cropping, as the mask should
+// be found from the whole page. The greyscale areas then count as
+// content like the text around them, so illustrations within the
+// page are kept, but anything found as non-text outside the content
+// area, such as a stamp in the margin, is still wiped.
+func KeepNonText(bin *image.Gray, orig image.Image, mask *image.Gray) *image.Gray {
+	b := bin.Bounds()
+	ob := orig.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, orig, ob.Min, draw.Src)
+
+	new := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := new.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			if mask.GrayAt(x, y).Y == 0 {
+				new.Pix[i] = gray.Pix[i]
+			} else {
+				new.Pix[i] = bin.Pix[bin.PixOffset(x, y)]
+			}
+		}
+	}
+
+	return new
+}
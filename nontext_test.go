@@ -0,0 +1,145 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestFindNonText(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	mask := FindNonText(bin, EstimateSizes(bin).LineHeight)
+
+	cases := []struct {
+		name string
+		r    image.Rectangle
+		min  float64
+		max  float64
+	}{
+		{"top diagram", image.Rect(300, 420, 1150, 680), 0.95, 1},
+		{"bottom diagram", image.Rect(600, 1420, 1150, 1580), 0.95, 1},
+		{"paragraphs", image.Rect(160, 750, 1290, 1325), 0, 0.01},
+		{"text below diagram", image.Rect(160, 1760, 1290, 2400), 0, 0.01},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := blackProportion(mask, c.r)
+			if p < c.min || p > c.max {
+				t.Errorf("Proportion of %v marked as non-text %.3f, expected %.2f-%.2f\n", c.r, p, c.min, c.max)
+			}
+		})
+	}
+}
+
+func TestFindNonTextShapes(t *testing.T) {
+	b := image.Rect(0, 0, 1200, 400)
+	img := whiteGray(b)
+	lineheight := 40
+
+	// lines of text-sized blobs
+	text := image.Rect(0, 0, 400, 400)
+	for y := 10; y < 400; y += lineheight {
+		for x := 10; x < 390; x += 20 {
+			fillRect(img, image.Rect(x, y, x+15, y+20))
+		}
+	}
+	// fine dots like broken hatching, which are sparse but tiny
+	hatching := image.Rect(450, 50, 750, 350)
+	for y := hatching.Min.Y; y < hatching.Max.Y; y += 5 {
+		for x := hatching.Min.X; x < hatching.Max.X; x += 5 {
+			fillRect(img, image.Rect(x, y, x+2, y+2))
+		}
+	}
+	// separate squares which are too dense to be text
+	dense := image.Rect(850, 50, 1150, 350)
+	for y := dense.Min.Y; y < dense.Max.Y; y += 7 {
+		for x := dense.Min.X; x < dense.Max.X; x += 7 {
+			fillRect(img, image.Rect(x, y, x+6, y+6))
+		}
+	}
+
+	mask := FindNonText(img, lineheight)
+	if p := blackProportion(mask, text); p != 0 {
+		t.Errorf("Proportion of text marked as non-text %.3f, expected 0\n", p)
+	}
+	if p := blackProportion(mask, hatching); p < 0.98 {
+		t.Errorf("Proportion of hatching marked as non-text %.3f, expected at least 0.98\n", p)
+	}
+	if p := blackProportion(mask, dense); p < 0.98 {
+		t.Errorf("Proportion of dense area marked as non-text %.3f, expected at least 0.98\n", p)
+	}
+}
+
+func TestKeepNonText(t *testing.T) {
+	img, err := decode("testdata/pg1.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	b := bin.Bounds()
+	mask := whiteGray(b)
+	keep := image.Rect(100, 100, 300, 200)
+	fillRect(mask, keep)
+
+	kept := KeepNonText(bin, img, mask)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := bin.GrayAt(x, y)
+			if image.Pt(x, y).In(keep) {
+				want = img.GrayAt(x, y)
+			}
+			if got := kept.GrayAt(x, y); got != want {
+				t.Fatalf("Pixel %d,%d is %d, expected %d\n", x, y, got.Y, want.Y)
+			}
+		}
+	}
+}
+
+func TestKeepNonTextWipe(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	b := bin.Bounds()
+	s := EstimateSizes(bin)
+
+	// a dark mark in the margin, like a library stamp, which is
+	// found as non-text but is outside the content area
+	stamp := image.Rect(20, 1000, 120, 1100)
+	fillRect(bin, stamp)
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	fillGray(gray, stamp, 0)
+
+	mask := FindNonText(bin, s.LineHeight)
+	if p := blackProportion(mask, stamp); p != 1 {
+		t.Fatalf("Proportion of stamp marked as non-text %.3f, expected 1\n", p)
+	}
+	kept := KeepNonText(bin, gray, mask)
+	clean := Wipe(VWipe(kept, s.VWipe, 0.005, 30), s.Wipe, 0.05, 30)
+
+	diagram := image.Rect(300, 420, 1150, 680)
+	var same int
+	for y := diagram.Min.Y; y < diagram.Max.Y; y++ {
+		for x := diagram.Min.X; x < diagram.Max.X; x++ {
+			if clean.GrayAt(x, y) == gray.GrayAt(x, y) {
+				same++
+			}
+		}
+	}
+	if p := float64(same) / float64(diagram.Dx()*diagram.Dy()); p < 0.95 {
+		t.Errorf("Proportion of diagram kept in greyscale after wiping %.3f, expected at least 0.95\n", p)
+	}
+	if !grayAll(clean, stamp, 255) {
+		t.Errorf("Non-text area outside the content area was not wiped\n")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"math"
+)
+
+// FindOrientation finds which way up the text in a binarised image
+// is, returning the clockwise rotation in degrees (0, 90, 180 or
+// 270) which should be applied with Rotate90 to make it upright,
+// and a confidence value from 0 to 1.
+//
+// Whether the lines of text run horizontally or vertically is
+// decided by whether the row or column projection profile varies
+// the most, as lines of text give strong peaks and troughs across
+// them. Which way up they are is then decided by comparing the
+// black pixels above and below the x-height band of each line, as
+// in Latin scripts ascenders and capitals are much more common
+// than descenders.
+//
+// The confidence is the lower of the confidences of these two
+// steps, each of which is the relative difference between the
+// scores of the two alternatives. Pages with little or no text,
+// such as blank pages and illustrations, give a low confidence and
+// often a wrong rotation, so a rotation should only be applied if
+// the confidence is above some minimum, such as 0.1.
+func FindOrientation(img *image.Gray) (int, float64) {
+	rowscore := profileScore(trimProfile(rowProfile(img)))
+	colscore := profileScore(trimProfile(rowProfile(Rotate90(img, 90))))
+
+	rotation := 0
+	if colscore > rowscore {
+		rotation = 90
+		img = Rotate90(img, 90)
+	}
+	rows := rowProfile(img)
+	axisconf := relDiff(rowscore, colscore)
+
+	above, below := lineAsymmetry(rows)
+	if below > above {
+		rotation += 180
+	}
+	flipconf := relDiff(above, below)
+
+	return rotation, math.Min(axisconf, flipconf)
+}
+
+// relDiff returns the difference between two non-negative numbers
+// relative to the greater of them
+func relDiff(a float64, b float64) float64 {
+	max := math.Max(a, b)
+	if max == 0 {
+		return 0
+	}
+	return math.Abs(a-b) / max
+}
+
+// trimProfile returns a profile without any zero values at the
+// start or end, so that blank margins do not affect profileScore
+func trimProfile(p []float64) []float64 {
+	for len(p) > 0 && p[0] == 0 {
+		p = p[1:]
+	}
+	for len(p) > 0 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// profileScore returns the variance of a profile relative to the
+// square of its mean, so that profiles of different lengths can be
+// compared
+func profileScore(profile []float64) float64 {
+	if len(profile) == 0 {
+		return 0
+	}
+	var sum, sqsum float64
+	for _, v := range profile {
+		sum += v
+		sqsum += v * v
+	}
+	n := float64(len(profile))
+	m := sum / n
+	if m == 0 {
+		return 0
+	}
+	return (sqsum/n - m*m) / (m * m)
+}
+
+// lineAsymmetry finds the lines of text in a row profile, and
+// returns the total number of black pixels above and below the
+// x-height band of each line, which is taken to be the rows which
+// have at least half as many black pixels as the densest row of
+// the line.
+func lineAsymmetry(rows []float64) (float64, float64) {
+	var max float64
+	for _, v := range rows {
+		if v > max {
+			max = v
+		}
+	}
+	gap := max / 20
+
+	var above, below float64
+	for y := 0; y < len(rows); {
+		if rows[y] <= gap {
+			y++
+			continue
+		}
+		start := y
+		var peak float64
+		for ; y < len(rows) && rows[y] > gap; y++ {
+			if rows[y] > peak {
+				peak = rows[y]
+			}
+		}
+		end := y
+
+		top, bottom := -1, -1
+		for i := start; i < end; i++ {
+			if rows[i]*2 >= peak {
+				if top == -1 {
+					top = i
+				}
+				bottom = i
+			}
+		}
+		for i := start; i < top; i++ {
+			above += rows[i]
+		}
+		for i := bottom + 1; i < end; i++ {
+			below += rows[i]
+		}
+	}
+
+	return above, below
+}
+
+// rotate90Pix rotates the pixels of src, which is w by h pixels
+// with channels bytes per pixel, clockwise by rotation degrees,
+// writing them to dst
+func rotate90Pix(dst []uint8, dstStride int, src []uint8, srcStride int, w int, h int, channels int, rotation int) {
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch rotation {
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			s := y*srcStride + x*channels
+			d := dy*dstStride + dx*channels
+			copy(dst[d:d+channels], src[s:s+channels])
+		}
+	}
+}
+
+// rotatedBounds returns the bounds of a w by h pixel image after
+// it has been rotated by rotation degrees
+func rotatedBounds(w int, h int, rotation int) image.Rectangle {
+	if rotation == 90 || rotation == 270 {
+		return image.Rect(0, 0, h, w)
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// normRotation returns a rotation in degrees as one of 0, 90, 180
+// or 270, rounding it to the nearest 90 degrees
+func normRotation(rotation int) int {
+	r := int(math.Round(float64(rotation)/90)) * 90 % 360
+	if r < 0 {
+		r += 360
+	}
+	return r
+}
+
+// Rotate90 rotates an image clockwise by a multiple of 90 degrees,
+// such as that returned by FindOrientation. Other rotations are
+// rounded to the nearest 90 degrees. The returned image starts at
+// 0, 0.
+func Rotate90(img *image.Gray, rotation int) *image.Gray {
+	rotation = normRotation(rotation)
+	b := img.Bounds()
+	new := image.NewGray(rotatedBounds(b.Dx(), b.Dy(), rotation))
+	rotate90Pix(new.Pix, new.Stride, img.Pix, img.Stride, b.Dx(), b.Dy(), 1, rotation)
+	return new
+}
+
+// Rotate90RGBA rotates an image clockwise by a multiple of 90
+// degrees, such as that returned by FindOrientation. Other
+// rotations are rounded to the nearest 90 degrees. The returned
+// image starts at 0, 0.
+func Rotate90RGBA(img *image.RGBA, rotation int) *image.RGBA {
+	rotation = normRotation(rotation)
+	b := img.Bounds()
+	new := image.NewRGBA(rotatedBounds(b.Dx(), b.Dy(), rotation))
+	rotate90Pix(new.Pix, new.Stride, img.Pix, img.Stride, b.Dx(), b.Dy(), 4, rotation)
+	return new
+}
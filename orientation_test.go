@@ -0,0 +1,62 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindOrientation(t *testing.T) {
+	cases := []string{
+		"testdata/pg2.png",
+		"testdata/1727_GREENE_0048.png",
+		"testdata/1687_SCHWEITZER_0030.png",
+		"testdata/pg1.png",
+		"testdata/0002.png",
+	}
+
+	for _, c := range cases {
+		img, err := decode(c)
+		if err != nil {
+			t.Fatalf("Could not open file %s: %v\n", c, err)
+		}
+		bin := Otsu(img)
+		for _, turn := range []int{0, 90, 180, 270} {
+			t.Run(fmt.Sprintf("%s_%d", c, turn), func(t *testing.T) {
+				rotation, conf := FindOrientation(Rotate90(bin, turn))
+				expected := (360 - turn) % 360
+				if rotation != expected {
+					t.Errorf("Found rotation %d, expected %d (confidence %0.2f)\n", rotation, expected, conf)
+				}
+				if conf <= 0 || conf > 1 {
+					t.Errorf("Confidence %0.2f out of range\n", conf)
+				}
+			})
+		}
+	}
+}
+
+func TestRotate90(t *testing.T) {
+	img, err := decode("testdata/pg2.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	b := img.Bounds()
+
+	r := Rotate90(img, 90)
+	if r.Bounds().Dx() != b.Dy() || r.Bounds().Dy() != b.Dx() {
+		t.Errorf("Rotated image has bounds %v, original %v\n", r.Bounds(), b)
+	}
+	if r.GrayAt(b.Dy()-1, 0) != img.GrayAt(0, 0) {
+		t.Errorf("Top left pixel not moved to top right\n")
+	}
+	if !imgsequal(Rotate90(Rotate90(r, 180), 90), img) {
+		t.Errorf("Rotating through 360 degrees changed the image\n")
+	}
+	if !imgsequal(Rotate90(img, -90), Rotate90(img, 270)) {
+		t.Errorf("Rotating by -90 differs from rotating by 270\n")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"rescribe.xyz/integral"
+)
+
+func init() {
+	RegisterBinarizer("otsu", func(p BinarizerParams) Binarizer {
+		return OtsuBinarizer{}
+	})
+	RegisterBinarizer("otsusauvola", func(p BinarizerParams) Binarizer {
+		return OtsuSauvolaBinarizer{K: p.K, Wsize: p.Wsize}
+	})
+}
+
+// OtsuBinarizer is a Binarizer which uses Otsu's algorithm. It
+// takes no parameters.
+type OtsuBinarizer struct{}
+
+// Binarize binarizes an image with Otsu.
+func (o OtsuBinarizer) Binarize(img image.Image) *image.Gray {
+	return Otsu(img)
+}
+
+// OtsuSauvolaBinarizer is a Binarizer which uses OtsuSauvola.
+type OtsuSauvolaBinarizer struct {
+	K     float64
+	Wsize int
+}
+
+// Binarize binarizes an image with OtsuSauvola.
+func (o OtsuSauvolaBinarizer) Binarize(img image.Image) *image.Gray {
+	return OtsuSauvola(img, o.K, o.Wsize)
+}
+
+// BinarizeIntegral binarizes an image with PreCalcedOtsuSauvola.
+func (o OtsuSauvolaBinarizer) BinarizeIntegral(intImg integral.Image, intSqImg integral.SqImage, img image.Image) *image.Gray {
+	return PreCalcedOtsuSauvola(intImg, intSqImg, img, o.K, o.Wsize)
+}
+
+// DefaultK returns 0.5, as for Sauvola.
+func (o OtsuSauvolaBinarizer) DefaultK() float64 {
+	return 0.5
+}
+
+// EstimateK estimates a good k value for an image with EstimateK.
+func (o OtsuSauvolaBinarizer) EstimateK(img image.Image) float64 {
+	return EstimateK(img, o.Wsize)
+}
+
+// histogram returns the number of pixels of each grey value in
+// an image
+func histogram(img *image.Gray) [256]int {
+	var hist [256]int
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			hist[img.GrayAt(x, y).Y]++
+		}
+	}
+	return hist
+}
+
+// OtsuThreshold finds a global threshold for an image with Otsu's
+// method, see paper "A threshold selection method from gray-level
+// histograms" (1979). Pixels darker than the threshold are
+// foreground. If the image is all one shade, the threshold will
+// be 0, so that every pixel is background.
+func OtsuThreshold(img image.Image) uint8 {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	hist := histogram(gray)
+
+	var total, sum float64
+	for i, n := range hist {
+		total += float64(n)
+		sum += float64(i * n)
+	}
+
+	var best, w0, sum0 float64
+	threshold := 0
+	for t := 0; t < 255; t++ {
+		w0 += float64(hist[t])
+		if w0 == 0 {
+			continue
+		}
+		w1 := total - w0
+		if w1 == 0 {
+			break
+		}
+		sum0 += float64(t * hist[t])
+		m0 := sum0 / w0
+		m1 := (sum - sum0) / w1
+		between := w0 * w1 * (m0 - m1) * (m0 - m1)
+		if between > best {
+			best = between
+			threshold = t + 1
+		}
+	}
+
+	return uint8(threshold)
+}
+
+// Otsu binarizes an image using a single global threshold found
+// with OtsuThreshold. This is fast and works well on clean, evenly
+// lit pages, but not on ones with uneven lighting or staining.
+func Otsu(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	new := image.NewGray(b)
+
+	threshold := OtsuThreshold(gray)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if gray.GrayAt(x, y).Y < threshold {
+				new.SetGray(x, y, color.Gray{0})
+			} else {
+				new.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+
+	return new
+}
+
+// OtsuSauvola binarizes an image with Sauvola's algorithm, except
+// that any window which contains no pixels darker than the global
+// Otsu threshold is considered to be background and set to white.
+// This prevents the salt noise that Sauvola can produce in large
+// blank areas like margins.
+func OtsuSauvola(img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, img, b.Min, draw.Src)
+	intSqImg := integral.NewSqImage(b)
+	draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+
+	return PreCalcedOtsuSauvola(*intImg, *intSqImg, img, ksize, windowsize)
+}
+
+// PreCalcedOtsuSauvola implements OtsuSauvola using precalculated
+// Integral Images
+func PreCalcedOtsuSauvola(intImg integral.Image, intSqImg integral.SqImage, img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+	new := PreCalcedSauvola(intImg, intSqImg, img, ksize, windowsize)
+
+	otsu := Otsu(img)
+	intOtsu := integral.NewImage(b)
+	draw.Draw(intOtsu, b, otsu, b.Min, draw.Src)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if new.GrayAt(x, y).Y != 0 {
+				continue
+			}
+			r := centeredRectangle(x, y, windowsize).Intersect(b)
+			// 1 << 16 - 1 as we're using Gray16, so 1 << 16 - 1 = white
+			numwhite := intOtsu.Sum(r) / (1<<16 - 1)
+			if numwhite == uint64(r.Dx()*r.Dy()) {
+				new.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+
+	return new
+}
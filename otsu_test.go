@@ -0,0 +1,86 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func TestOtsuThreshold(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			v := uint8(200 + (x+y)%20)
+			if x > 40 && x < 60 {
+				v = uint8(30 + (x+y)%20)
+			}
+			img.SetGray(x, y, color.Gray{v})
+		}
+	}
+	threshold := OtsuThreshold(img)
+	if threshold < 50 || threshold > 200 {
+		t.Errorf("Threshold %d is not between the two classes\n", threshold)
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, 10, 10))
+	if threshold = OtsuThreshold(blank); threshold != 0 {
+		t.Errorf("Threshold for blank image is %d, not 0\n", threshold)
+	}
+}
+
+func TestOtsu(t *testing.T) {
+	cases := []struct {
+		name   string
+		orig   string
+		golden string
+		ksize  float64
+		wsize  int
+	}{
+		{"otsu", "testdata/pg1.png", "testdata/pg1_otsu.png", 0, 0},
+		{"otsusauvola", "testdata/pg1.png", "testdata/pg1_otsusauvola_k0.5_w41.png", 0.5, 41},
+		{"otsusauvola", "testdata/pg1.png", "testdata/pg1_otsusauvola_k0.3_w19.png", 0.3, 19},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%0.1f_%d", c.name, c.ksize, c.wsize), func(t *testing.T) {
+			var actual *image.Gray
+			orig, err := decode(c.orig)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.orig, err)
+			}
+			switch c.name {
+			case "otsu":
+				actual = Otsu(orig)
+			case "otsusauvola":
+				actual = OtsuSauvola(orig, c.ksize, c.wsize)
+			default:
+				t.Fatalf("No method %s\n", c.name)
+			}
+			if *update {
+				f, err := os.Create(c.golden)
+				defer f.Close()
+				if err != nil {
+					t.Fatalf("Could not open file %s to update: %v\n", c.golden, err)
+				}
+				err = png.Encode(f, actual)
+				if err != nil {
+					t.Fatalf("Could not encode update of %s: %v\n", c.golden, err)
+				}
+			}
+			golden, err := decode(c.golden)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.golden, err)
+			}
+			if !imgsequal(golden, actual) {
+				t.Errorf("Binarized %s differs to %s\n", c.orig, c.golden)
+			}
+		})
+	}
+}
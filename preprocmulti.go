@@ -16,23 +16,52 @@ import (
 	"rescribe.xyz/integral"
 )
 
-func autowsize(bounds image.Rectangle) int {
-	// TODO: do more testing to see how good this assumption is
-	return bounds.Dx() / 60
+// PreProcMultiOptions are the settings used by PreProcMultiWith.
+type PreProcMultiOptions struct {
+	BinAlgorithm      string    // Name of the registered Binarizer to use, e.g. sauvola
+	Ksizes            []float64 // k values to pass to the binarization algorithm
+	AutoK             bool      // Whether to also binarize with a k value estimated by the Binarizer, which must be a KEstimator
+	BinType           string    // Type of binarization threshold. One of those listed in BinTypes.
+	BinWsize          int       // Window size for binarization algorithm. Set automatically with EstimateSizes if 0.
+	Wipe              bool      // Whether to wipe (clear sides) the image
+	WipeWsize         int       // Window size for wiping algorithm. Set automatically with EstimateSizes if 0.
+	WipeMinWidthPerc  int       // Minimum percentage of the image width for the content width calculation to be considered valid
+	VWipeWsize        int       // Window size for vertical wiping algorithm. Set automatically with EstimateSizes if 0.
+	WipeMinHeightPerc int       // Minimum percentage of the image height for the content height calculation to be considered valid
+	Despeckle         bool      // Whether to remove specks of noise after binarization
+	DespeckleSize     int       // Largest speck size in pixels to remove. Set automatically with EstimateSizes if 0.
 }
 
-// PreProcMulti binarizes and preprocesses an image with multiple binarisation levels.
+// PreProcMulti binarizes and preprocesses an image with multiple binarisation levels,
+// using the sauvola Binarizer. It is a shorthand for PreProcMultiWith.
 // inPath: Path of input image.
 // ksizes: Slice of k values to pass to Sauvola algorithm
-// binType: Type of binarization threshold. binary or zeroinv are currently implemented.
-// binWsize: Window size for sauvola binarization algorithm. Set automatically based on resolution if 0.
+// binType: Type of binarization threshold. One of those listed in BinTypes.
+// binWsize: Window size for sauvola binarization algorithm. Set automatically with EstimateSizes if 0.
 // wipe: Whether to wipe (clear sides) the image
 // wipeWsize: Window size for wiping algorithm
 // wipeMinWidthPerc: Minimum percentage of the image width for the content width calculation to be considered valid
 // vWipeWsize: Window size for vertical wiping algorithm
 // wipeMinHeightPerc: Minimum percentage of the image height for the content height calculation to be considered valid
 func PreProcMulti(inPath string, ksizes []float64, binType string, binWsize int, wipe bool, wipeWsize int, wipeMinWidthPerc int, vWipeWsize int, wipeMinHeightPerc int) ([]string, error) {
-	// TODO: come up with a way to set a good ksize automatically
+	return PreProcMultiWith(inPath, PreProcMultiOptions{
+		BinAlgorithm:      "sauvola",
+		Ksizes:            ksizes,
+		BinType:           binType,
+		BinWsize:          binWsize,
+		Wipe:              wipe,
+		WipeWsize:         wipeWsize,
+		WipeMinWidthPerc:  wipeMinWidthPerc,
+		VWipeWsize:        vWipeWsize,
+		WipeMinHeightPerc: wipeMinHeightPerc,
+	})
+}
+
+// PreProcMultiWith binarizes and preprocesses an image with multiple
+// binarisation levels, with the settings in o, saving each version
+// alongside inPath. It returns the paths of the images saved.
+func PreProcMultiWith(inPath string, o PreProcMultiOptions) ([]string, error) {
+	binWsize, wipeWsize, vWipeWsize, despeckleSize := o.BinWsize, o.WipeWsize, o.VWipeWsize, o.DespeckleSize
 
 	// Make outBase inPath up to final .
 	s := strings.Split(inPath, ".")
@@ -51,45 +80,93 @@ func PreProcMulti(inPath string, ksizes []float64, binType string, binWsize int,
 	}
 
 	b := img.Bounds()
-	if binWsize == 0 {
-		binWsize = autowsize(b)
+	if binWsize == 0 || wipeWsize == 0 || vWipeWsize == 0 || (o.Despeckle && despeckleSize == 0) {
+		sizes := EstimateSizes(img)
+		if binWsize == 0 {
+			binWsize = sizes.Binarize
+		}
+		if wipeWsize == 0 {
+			wipeWsize = sizes.Wipe
+		}
+		if vWipeWsize == 0 {
+			vWipeWsize = sizes.VWipe
+		}
+		if despeckleSize == 0 {
+			despeckleSize = sizes.Despeckle
+		}
 	}
 
 	if binWsize%2 == 0 {
 		binWsize++
 	}
 
-	intImg := integral.NewImage(b)
-	draw.Draw(intImg, b, img, b.Min, draw.Src)
-	intSqImg := integral.NewSqImage(b)
-	draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+	var intImg *integral.Image
+	var intSqImg *integral.SqImage
+	precalc := func() {
+		if intImg != nil {
+			return
+		}
+		intImg = integral.NewImage(b)
+		draw.Draw(intImg, b, img, b.Min, draw.Src)
+		intSqImg = integral.NewSqImage(b)
+		draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+	}
 
-	var clean, threshimg image.Image
+	var clean, threshimg *image.Gray
+	var out image.Image
 
-	for _, k := range ksizes {
-		threshimg = PreCalcedSauvola(*intImg, *intSqImg, img, k, binWsize)
+	// the last pass is for the estimated k value, if requested
+	for i := 0; i <= len(o.Ksizes); i++ {
+		auto := i == len(o.Ksizes)
+		if auto && !o.AutoK {
+			break
+		}
 
-		if binType == "zeroinv" {
-			threshimg, err = BinToZeroInv(threshimg.(*image.Gray), img.(*image.RGBA))
-			if err != nil {
-				return donePaths, fmt.Errorf("Error in BinToZeroInv: %v", err)
-			}
+		var bin Binarizer
+		var k float64
+		if auto {
+			bin, k, err = NewAutoKBinarizer(o.BinAlgorithm, binWsize, img)
+		} else {
+			k = o.Ksizes[i]
+			bin, err = NewBinarizer(o.BinAlgorithm, BinarizerParams{K: k, Wsize: binWsize})
+		}
+		if err != nil {
+			return donePaths, fmt.Errorf("Error setting up binarizer: %v", err)
+		}
+
+		if ib, ok := bin.(IntegralBinarizer); ok {
+			precalc()
+			threshimg = ib.BinarizeIntegral(*intImg, *intSqImg, img)
+		} else {
+			threshimg = bin.Binarize(img)
+		}
+
+		if o.Despeckle {
+			threshimg = Despeckle(threshimg, despeckleSize, false)
 		}
 
-		if wipe {
-			vclean := VWipe(threshimg.(*image.Gray), vWipeWsize, k*0.02, wipeMinHeightPerc)
-			clean = Wipe(vclean, wipeWsize, k*0.02, wipeMinWidthPerc)
+		if o.Wipe {
+			vclean := VWipe(threshimg, vWipeWsize, k*0.02, o.WipeMinHeightPerc)
+			clean = Wipe(vclean, wipeWsize, k*0.02, o.WipeMinWidthPerc)
 		} else {
 			clean = threshimg
 		}
 
+		out, err = BinToType(o.BinType, clean, img)
+		if err != nil {
+			return donePaths, fmt.Errorf("Error converting threshold type: %v", err)
+		}
+
 		savefn := fmt.Sprintf("%s_bin%0.1f.png", outBase, k)
+		if auto {
+			savefn = fmt.Sprintf("%s_binauto.png", outBase)
+		}
 		f, err = os.Create(savefn)
 		if err != nil {
 			return donePaths, fmt.Errorf("Error creating file %s: %v", savefn, err)
 		}
 		defer f.Close()
-		err = png.Encode(f, clean)
+		err = png.Encode(f, out)
 		if err != nil {
 			return donePaths, fmt.Errorf("Error encoding image %s as png: %v", savefn, err)
 		}
@@ -0,0 +1,128 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Rule is a straight horizontal or vertical line found by FindRules,
+// such as a table ruling or an underline.
+type Rule struct {
+	Bounds   image.Rectangle // Bounding box of the line
+	Vertical bool            // Whether the line is vertical rather than horizontal
+}
+
+// FindRules finds long thin straight lines in a binarised image,
+// such as the rulings of a table or form and underlines, which are
+// at least minlength pixels long and on average no more than
+// maxwidth pixels thick. Horizontal lines are found by opening the
+// image with a minlength wide row, which keeps only runs of black
+// pixels at least that long, and vertical lines likewise with a
+// column. Anything thicker than maxwidth, such as a solid black
+// area, is ignored. A good minlength is given by EstimateSizes, and
+// its Border size is a good maxwidth.
+func FindRules(img *image.Gray, minlength int, maxwidth int) []Rule {
+	_, hrules := ruleMask(img, minlength, maxwidth, false)
+	_, vrules := ruleMask(img, minlength, maxwidth, true)
+	return append(hrules, vrules...)
+}
+
+// RemoveRules finds lines with FindRules and removes them, returning
+// the cleaned image and the lines which were found.
+//
+// Where a stroke of a character crosses a line, with black pixels
+// on both sides of it, the part of the line it crosses is kept, so
+// that characters are not cut in two. Characters which only touch
+// a line, such as descenders sitting on an underline, are not
+// repaired.
+func RemoveRules(img *image.Gray, minlength int, maxwidth int) (*image.Gray, []Rule) {
+	hmask, hrules := ruleMask(img, minlength, maxwidth, false)
+	vmask, vrules := ruleMask(img, minlength, maxwidth, true)
+
+	new := image.NewGray(img.Bounds())
+	draw.Draw(new, new.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	eraseColumns(new, img, hmask, vmask)
+	t := transposeGray(new)
+	eraseColumns(t, transposeGray(img), transposeGray(vmask), transposeGray(hmask))
+
+	return transposeGray(t), append(hrules, vrules...)
+}
+
+// ruleMask finds horizontal or vertical lines in an image, returning
+// a mask which is black where the pixels of the lines are, and the
+// lines themselves
+func ruleMask(img *image.Gray, minlength int, maxwidth int, vertical bool) (*image.Gray, []Rule) {
+	b := img.Bounds()
+	se := RectElement(minlength, 1)
+	if vertical {
+		se = RectElement(1, minlength)
+	}
+	labels, comps := Components(Open(img, se), EightConnected)
+
+	var rules []Rule
+	keep := make([]bool, len(comps))
+	for i, c := range comps {
+		long := c.Bounds.Dx()
+		if vertical {
+			long = c.Bounds.Dy()
+		}
+		if c.Area > long*maxwidth {
+			continue
+		}
+		keep[i] = true
+		rules = append(rules, Rule{c.Bounds, vertical})
+	}
+
+	mask := image.NewGray(b)
+	draw.Draw(mask, b, &image.Uniform{color.Gray{255}}, image.Point{}, draw.Src)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := mask.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+			if l := labels.LabelAt(x, y); l > 0 && keep[l-1] {
+				mask.Pix[i] = 0
+			}
+		}
+	}
+
+	return mask, rules
+}
+
+// eraseColumns whitens the pixels of new which are black in mask,
+// going down each column a run at a time, unless img has black
+// pixels directly above and below the run, which are taken to be a
+// stroke crossing the line. Pixels which are black in other, the
+// mask of lines in the other direction, don't count as strokes.
+func eraseColumns(new *image.Gray, img *image.Gray, mask *image.Gray, other *image.Gray) {
+	b := img.Bounds()
+	stroke := func(x, y int) bool {
+		if y < b.Min.Y || y >= b.Max.Y {
+			return false
+		}
+		return img.GrayAt(x, y).Y < 128 && other.GrayAt(x, y).Y >= 128
+	}
+
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; {
+			if mask.GrayAt(x, y).Y >= 128 {
+				y++
+				continue
+			}
+			start := y
+			for y < b.Max.Y && mask.GrayAt(x, y).Y < 128 {
+				y++
+			}
+			if stroke(x, start-1) && stroke(x, y) {
+				continue
+			}
+			for yy := start; yy < y; yy++ {
+				new.SetGray(x, yy, color.Gray{255})
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRemoveRules(t *testing.T) {
+	img := whiteGray(image.Rect(0, 0, 600, 400))
+	hline := image.Rect(50, 100, 550, 103)
+	vline := image.Rect(300, 50, 303, 350)
+	// a stroke of a character crossing the horizontal line
+	stroke := image.Rect(100, 80, 104, 120)
+	// a solid area, which is too thick to be a line
+	block := image.Rect(350, 200, 550, 300)
+	for _, r := range []image.Rectangle{hline, vline, stroke, block} {
+		fillRect(img, r)
+	}
+
+	rules := FindRules(img, 100, 5)
+	expected := []Rule{{hline, false}, {vline, true}}
+	if len(rules) != len(expected) {
+		t.Fatalf("Found rules %v, expected %v\n", rules, expected)
+	}
+	for i := range rules {
+		if rules[i] != expected[i] {
+			t.Errorf("Found rule %v, expected %v\n", rules[i], expected[i])
+		}
+	}
+
+	clean, _ := RemoveRules(img, 100, 5)
+	cases := []struct {
+		name  string
+		r     image.Rectangle
+		black float64
+	}{
+		{"horizontal line", image.Rect(50, 100, 100, 103), 0},
+		{"vertical line", vline, 0},
+		{"crossing stroke", stroke, 1},
+		{"block", block, 1},
+	}
+	for _, c := range cases {
+		if p := blackProportion(clean, c.r); p != c.black {
+			t.Errorf("Proportion of %s which is black %.3f, expected %.0f\n", c.name, p, c.black)
+		}
+	}
+}
+
+func TestRemoveRulesText(t *testing.T) {
+	img, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	bin := Otsu(img)
+	s := EstimateSizes(bin)
+
+	// draw a table rule and an underline among the paragraphs
+	para := image.Rect(160, 750, 1290, 1325)
+	rule := image.Rect(para.Min.X, 1000, para.Max.X, 1000+s.StrokeWidth)
+	underline := image.Rect(400, 1071, 700, 1071+s.StrokeWidth)
+	lined := image.NewGray(bin.Bounds())
+	copy(lined.Pix, bin.Pix)
+	fillRect(lined, rule)
+	fillRect(lined, underline)
+
+	clean, rules := RemoveRules(lined, s.Rule, s.Border)
+	var found int
+	for _, r := range rules {
+		if r.Bounds.Overlaps(para) {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Found %d rules in the paragraphs, expected 2\n", found)
+	}
+
+	// text touching the ends of a line in the same rows can't be told
+	// apart from it, so only the other rows are checked
+	var changed int
+	for y := para.Min.Y; y < para.Max.Y; y++ {
+		if (y >= rule.Min.Y && y < rule.Max.Y) || (y >= underline.Min.Y && y < underline.Max.Y) {
+			continue
+		}
+		for x := para.Min.X; x < para.Max.X; x++ {
+			if clean.GrayAt(x, y) != bin.GrayAt(x, y) {
+				changed++
+			}
+		}
+	}
+	if changed > 0 {
+		t.Errorf("Removing rules changed %d pixels of text\n", changed)
+	}
+	if p := blackProportion(clean, rule); p > 0.1 {
+		t.Errorf("Proportion of rule left after removal %.3f, expected no more than 0.1\n", p)
+	}
+}
@@ -6,30 +6,132 @@ package preproc
 
 import (
 	"image"
-	"image/color"
 	"image/draw"
 	"math"
+	"runtime"
 
 	"rescribe.xyz/integral"
 )
 
+func init() {
+	RegisterBinarizer("sauvola", func(p BinarizerParams) Binarizer {
+		return SauvolaBinarizer{K: p.K, Wsize: p.Wsize}
+	})
+}
+
+// SauvolaBinarizer is a Binarizer which uses the Integral Image
+// implementation of Sauvola's algorithm.
+type SauvolaBinarizer struct {
+	K     float64
+	Wsize int
+}
+
+// Binarize binarizes an image with IntegralSauvola.
+func (s SauvolaBinarizer) Binarize(img image.Image) *image.Gray {
+	return IntegralSauvola(img, s.K, s.Wsize)
+}
+
+// BinarizeIntegral binarizes an image with PreCalcedSauvola.
+func (s SauvolaBinarizer) BinarizeIntegral(intImg integral.Image, intSqImg integral.SqImage, img image.Image) *image.Gray {
+	return PreCalcedSauvola(intImg, intSqImg, img, s.K, s.Wsize)
+}
+
+// DefaultK returns 0.5, which works well for most images.
+func (s SauvolaBinarizer) DefaultK() float64 {
+	return 0.5
+}
+
+// EstimateK estimates a good k value for an image with EstimateK.
+func (s SauvolaBinarizer) EstimateK(img image.Image) float64 {
+	return EstimateK(img, s.Wsize)
+}
+
 // Implements Sauvola's algorithm for text binarization, see paper
 // "Adaptive document image binarization" (2000)
+//
+// The sums of the window are kept up to date as it slides across
+// the image, rather than being recalculated for every pixel. For
+// each row the sums of every column of the window are updated,
+// and then the window slides along them, adding the column which
+// enters it and removing the one which leaves it. The window and
+// the sample standard deviation are the same as those of the
+// original per-pixel implementation, so the output is identical.
 func Sauvola(img image.Image, ksize float64, windowsize int) *image.Gray {
 	b := img.Bounds()
 	gray := image.NewGray(b)
 	draw.Draw(gray, b, img, b.Min, draw.Src)
 	new := image.NewGray(b)
 
+	step := windowsize / 2
+	colsum := make([]uint64, b.Dx())
+	colsqsum := make([]uint64, b.Dx())
+
+	addrow := func(y int, add bool) {
+		if y < b.Min.Y || y >= b.Max.Y {
+			return
+		}
+		row := gray.Pix[gray.PixOffset(b.Min.X, y):]
+		for i := range colsum {
+			v := uint64(row[i])
+			if add {
+				colsum[i] += v
+				colsqsum[i] += v * v
+			} else {
+				colsum[i] -= v
+				colsqsum[i] -= v * v
+			}
+		}
+	}
+
+	// the window around a pixel covers from step+1 pixels before it
+	// to step-1 pixels after it, cut off at the edges of the image,
+	// so for y it covers the rows from y-step-1 to y+step-1
+	for y := b.Min.Y - step - 1; y < b.Min.Y+step-1; y++ {
+		addrow(y, true)
+	}
+
 	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			window := surrounding(gray, x, y, windowsize)
-			m, dev := meanstddev(window)
-			threshold := m * (1 + ksize*((dev/128)-1))
-			if gray.GrayAt(x, y).Y < uint8(math.Round(threshold)) {
-				new.SetGray(x, y, color.Gray{0})
+		addrow(y+step-1, true)
+		addrow(y-step-2, false)
+		miny, maxy := y-step-1, y+step
+		if miny < b.Min.Y {
+			miny = b.Min.Y
+		}
+		if maxy > b.Max.Y {
+			maxy = b.Max.Y
+		}
+		rows := maxy - miny
+
+		var sum, sqsum uint64
+		for i := 0; i < step-1 && i < len(colsum); i++ {
+			sum += colsum[i]
+			sqsum += colsqsum[i]
+		}
+
+		i := gray.PixOffset(b.Min.X, y)
+		for x := 0; x < b.Dx(); x, i = x+1, i+1 {
+			if in := x + step - 1; in >= 0 && in < b.Dx() {
+				sum += colsum[in]
+				sqsum += colsqsum[in]
+			}
+			if out := x - step - 2; out >= 0 {
+				sum -= colsum[out]
+				sqsum -= colsqsum[out]
+			}
+			minx, maxx := x-step-1, x+step
+			if minx < 0 {
+				minx = 0
+			}
+			if maxx > b.Dx() {
+				maxx = b.Dx()
+			}
+			n := uint64((maxx - minx) * rows)
+
+			m, dev := sumsMeanStdDev(n, sum, sqsum)
+			if gray.Pix[i] < sauvolaThreshold(m, dev, ksize) {
+				new.Pix[i] = 0
 			} else {
-				new.SetGray(x, y, color.Gray{255})
+				new.Pix[i] = 255
 			}
 		}
 	}
@@ -52,33 +154,42 @@ func IntegralSauvola(img image.Image, ksize float64, windowsize int) *image.Gray
 	return PreCalcedSauvola(*intImg, *intSqImg, img, ksize, windowsize)
 }
 
-// PreCalcedSauvola Implements Sauvola's algorithm using precalculated Integral Images
+// PreCalcedSauvola Implements Sauvola's algorithm using precalculated Integral Images.
+// The work is split between as many goroutines as there are CPUs.
 func PreCalcedSauvola(intImg integral.Image, intSqImg integral.SqImage, img image.Image, ksize float64, windowsize int) *image.Gray {
+	return ParallelPreCalcedSauvola(intImg, intSqImg, img, ksize, windowsize, runtime.NumCPU())
+}
+
+// ParallelPreCalcedSauvola implements PreCalcedSauvola, splitting
+// the image into horizontal bands which are binarized by workers
+// goroutines concurrently. The output is the same for any number
+// of workers.
+func ParallelPreCalcedSauvola(intImg integral.Image, intSqImg integral.SqImage, img image.Image, ksize float64, windowsize int, workers int) *image.Gray {
 	b := img.Bounds()
 	gray := image.NewGray(b)
 	draw.Draw(gray, b, img, b.Min, draw.Src)
 	new := image.NewGray(b)
 
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			r := centeredRectangle(x, y, windowsize)
-			m, dev := integral.MeanStdDev(intImg, intSqImg, r)
-			// Divide by 255 to adjust from Gray16 used by integral.Image to 8 bit Gray
-			m8 := m / 255
-			dev8 := dev / 255
-			threshold := m8 * (1 + ksize*((dev8/128)-1))
-			if gray.GrayAt(x, y).Y < uint8(math.Round(threshold)) {
-				new.SetGray(x, y, color.Gray{0})
-			} else {
-				new.SetGray(x, y, color.Gray{255})
+	inBands(b, workers, func(miny, maxy int) {
+		for y := miny; y < maxy; y++ {
+			i := gray.PixOffset(b.Min.X, y)
+			for x := b.Min.X; x < b.Max.X; x, i = x+1, i+1 {
+				r := centeredRectangle(x, y, windowsize)
+				m, dev := integralMeanStdDev(intImg, intSqImg, r)
+				if gray.Pix[i] < sauvolaThreshold(m, dev, ksize) {
+					new.Pix[i] = 0
+				} else {
+					new.Pix[i] = 255
+				}
 			}
 		}
-	}
+	})
 
 	return new
 }
 
-func centeredRectangle(x, y, size int) image.Rectangle {
-	step := size / 2
-	return image.Rect(x-step-1, y-step-1, x+step, y+step)
+// sauvolaThreshold returns the threshold for a pixel, given the
+// mean and standard deviation of the window around it
+func sauvolaThreshold(m, dev, ksize float64) uint8 {
+	return uint8(math.Round(m * (1 + ksize*((dev/128)-1))))
 }
@@ -5,11 +5,18 @@
 package preproc
 
 import (
+	"bytes"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/png"
+	"math"
 	"os"
+	"runtime"
 	"testing"
+
+	"rescribe.xyz/integral"
 )
 
 func TestBinarization(t *testing.T) {
@@ -68,3 +75,217 @@ func TestBinarization(t *testing.T) {
 		})
 	}
 }
+
+// surrounding gets the pixel values surrounding a point in the
+// image, as the original per-pixel Sauvola did
+func surrounding(img *image.Gray, x int, y int, size int) []int {
+	b := img.Bounds()
+	step := size / 2
+
+	miny := y - step - 1
+	if miny < b.Min.Y {
+		miny = b.Min.Y
+	}
+	minx := x - step - 1
+	if minx < b.Min.X {
+		minx = b.Min.X
+	}
+	maxy := y + step
+	if maxy > b.Max.Y {
+		maxy = b.Max.Y
+	}
+	maxx := x + step
+	if maxx > b.Max.X {
+		maxx = b.Max.X
+	}
+
+	var s []int
+	for yi := miny; yi < maxy; yi++ {
+		for xi := minx; xi < maxx; xi++ {
+			s = append(s, int(img.GrayAt(xi, yi).Y))
+		}
+	}
+	return s
+}
+
+// meanstddev returns the mean and sample standard deviation of
+// some values, as the original per-pixel Sauvola did
+func meanstddev(i []int) (float64, float64) {
+	var total int
+	for _, n := range i {
+		total += n
+	}
+	m := float64(total) / float64(len(i))
+
+	var sum float64
+	for _, n := range i {
+		sum += (float64(n) - m) * (float64(n) - m)
+	}
+	variance := float64(sum) / float64(len(i)-1)
+	return m, math.Sqrt(variance)
+}
+
+// referenceSauvola is the original per-pixel implementation of
+// Sauvola, which the faster implementations must match exactly
+func referenceSauvola(img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	new := image.NewGray(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			window := surrounding(gray, x, y, windowsize)
+			m, dev := meanstddev(window)
+			threshold := m * (1 + ksize*((dev/128)-1))
+			if gray.GrayAt(x, y).Y < uint8(math.Round(threshold)) {
+				new.SetGray(x, y, color.Gray{0})
+			} else {
+				new.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+
+	return new
+}
+
+func TestSauvolaMatches(t *testing.T) {
+	cases := []struct {
+		filename string
+		ksize    float64
+		wsize    int
+		long     bool
+	}{
+		{"testdata/pg1.png", 0.5, 41, true},
+		{"testdata/pg1.png", 0.5, 19, true},
+		{"testdata/pg1.png", 0.3, 19, true},
+		{"testdata/0002.png", 0.5, 20, true},
+		{"testdata/0002.png", 0.2, 3, true},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%0.1f_%d", c.filename, c.ksize, c.wsize), func(t *testing.T) {
+			if c.long && testing.Short() {
+				t.Skip("Skipping long test due to -short flag.\n")
+			}
+			orig, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			if !imgsequal(Sauvola(orig, c.ksize, c.wsize), referenceSauvola(orig, c.ksize, c.wsize)) {
+				t.Errorf("Sauvola differs to the per-pixel Sauvola\n")
+			}
+		})
+	}
+
+	// a window larger than the image, which is cut off on every side
+	t.Run("small", func(t *testing.T) {
+		orig, err := decode("testdata/pg1.png")
+		if err != nil {
+			t.Fatalf("Could not open file: %v\n", err)
+		}
+		small := image.NewGray(image.Rect(10, 20, 60, 50))
+		draw.Draw(small, small.Bounds(), orig, image.Pt(300, 300), draw.Src)
+		if !imgsequal(Sauvola(small, 0.5, 71), referenceSauvola(small, 0.5, 71)) {
+			t.Errorf("Sauvola differs to the per-pixel Sauvola on a small image\n")
+		}
+	})
+}
+
+func TestIntegralSauvolaMatches(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test due to -short flag.\n")
+	}
+
+	cases := []struct {
+		filename string
+		ksize    float64
+		wsize    int
+	}{
+		{"testdata/pg1.png", 0.5, 41},
+		{"testdata/pg1.png", 0.5, 19},
+		{"testdata/pg1.png", 0.3, 19},
+		{"testdata/pg1.png", 0.5, 20},
+		{"testdata/0002.png", 0.5, 11},
+		{"testdata/1727_GREENE_0048.png", 0.3, 17},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s_%0.1f_%d", c.filename, c.ksize, c.wsize), func(t *testing.T) {
+			orig, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			integral := IntegralSauvola(orig, c.ksize, c.wsize)
+			ref := referenceSauvola(orig, c.ksize, c.wsize)
+			b := ref.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if ref.GrayAt(x, y) != integral.GrayAt(x, y) {
+						t.Fatalf("IntegralSauvola differs to the per-pixel Sauvola at %d,%d\n", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParallelPreCalcedSauvola(t *testing.T) {
+	orig, err := decode("testdata/0002.png")
+	if err != nil {
+		t.Fatalf("Could not open file testdata/0002.png: %v\n", err)
+	}
+	b := orig.Bounds()
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, orig, b.Min, draw.Src)
+	intSqImg := integral.NewSqImage(b)
+	draw.Draw(intSqImg, b, orig, b.Min, draw.Src)
+
+	serial := ParallelPreCalcedSauvola(*intImg, *intSqImg, orig, 0.5, 19, 1)
+	for _, workers := range []int{2, 3, 8, b.Dy() + 1} {
+		t.Run(fmt.Sprintf("%d", workers), func(t *testing.T) {
+			actual := ParallelPreCalcedSauvola(*intImg, *intSqImg, orig, 0.5, 19, workers)
+			if !bytes.Equal(serial.Pix, actual.Pix) {
+				t.Errorf("Output with %d workers differs to serial output\n", workers)
+			}
+		})
+	}
+}
+
+func BenchmarkPreCalcedSauvola(b *testing.B) {
+	orig, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		b.Fatalf("Could not open file testdata/1727_GREENE_0048.png: %v\n", err)
+	}
+	r := orig.Bounds()
+	intImg := integral.NewImage(r)
+	draw.Draw(intImg, r, orig, r.Min, draw.Src)
+	intSqImg := integral.NewSqImage(r)
+	draw.Draw(intSqImg, r, orig, r.Min, draw.Src)
+
+	workerss := []int{1, 2, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		workerss = append(workerss, n)
+	}
+	for _, workers := range workerss {
+		b.Run(fmt.Sprintf("workers%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ParallelPreCalcedSauvola(*intImg, *intSqImg, orig, 0.5, 19, workers)
+			}
+		})
+	}
+}
+
+func BenchmarkSauvola(b *testing.B) {
+	orig, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		b.Fatalf("Could not open file testdata/1727_GREENE_0048.png: %v\n", err)
+	}
+	for _, wsize := range []int{19, 41} {
+		b.Run(fmt.Sprintf("w%d", wsize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Sauvola(orig, 0.5, wsize)
+			}
+		})
+	}
+}
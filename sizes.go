@@ -0,0 +1,182 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+)
+
+// Sizes contains measurements of the text in a page image, along
+// with window sizes for processing it which are based on them.
+type Sizes struct {
+	LineHeight  int // Typical distance from one line of text to the next
+	StrokeWidth int // Typical width of a text stroke
+	Binarize    int // Window size for binarization
+	Wipe        int // Window size for Wipe
+	VWipe       int // Window size for VWipe
+	Despeckle   int // Largest speck size in pixels for Despeckle
+	Border      int // Smallest border thickness for RemoveBorder
+	Flatten     int // Window size for FlattenBackground
+	Rule        int // Shortest line length for RemoveRules
+}
+
+// EstimateSizes measures the typical line height and stroke width
+// of the text in an image, and recommends window sizes for
+// binarization and wiping based on them. If the text can't be
+// measured, for example because the page is blank, the window
+// sizes fall back to defaults based on the image width.
+//
+// The speck size for Despeckle is half the area of a square of the
+// stroke width, so that it is smaller than a full stop, but at least
+// 1 so that single pixel specks are still removed from thin text.
+// The border thickness for RemoveBorder is four times the stroke
+// width, so that it is thicker than even bold text. The shortest
+// line for RemoveRules is three line heights, which is longer than
+// any run of black pixels in ordinary text.
+func EstimateSizes(img image.Image) Sizes {
+	b := img.Bounds()
+	bin := Otsu(img)
+
+	s := Sizes{
+		StrokeWidth: strokeWidth(bin),
+		LineHeight:  lineHeight(bin),
+	}
+
+	s.Binarize = b.Dx() / 60
+	s.Wipe = 5
+	s.VWipe = 120
+	s.Flatten = 120
+	s.Despeckle = 4
+	s.Border = 20
+	s.Rule = 100
+	if s.StrokeWidth > 0 {
+		s.Wipe = s.StrokeWidth * 2
+		s.Despeckle = s.StrokeWidth * s.StrokeWidth / 2
+		if s.Despeckle < 1 {
+			s.Despeckle = 1
+		}
+		s.Border = s.StrokeWidth * 4
+	}
+	if s.LineHeight > 0 {
+		s.Binarize = s.LineHeight / 2
+		s.VWipe = s.LineHeight * 2
+		s.Flatten = s.LineHeight * 2
+		s.Rule = s.LineHeight * 3
+	}
+	if s.Binarize < s.StrokeWidth*3 {
+		s.Binarize = s.StrokeWidth * 3
+	}
+	if s.Binarize%2 == 0 {
+		s.Binarize++
+	}
+
+	return s
+}
+
+// strokeWidth finds the most common length of horizontal runs of
+// black pixels in a binary image, which is the typical width of a
+// vertical text stroke. It returns 0 if there are no black pixels.
+func strokeWidth(img *image.Gray) int {
+	b := img.Bounds()
+	maxrun := b.Dx() / 20
+	runs := make([]int, maxrun+1)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		run := 0
+		for x := b.Min.X; x <= b.Max.X; x++ {
+			if x < b.Max.X && img.GrayAt(x, y).Y == 0 {
+				run++
+				continue
+			}
+			if run > 0 && run <= maxrun {
+				runs[run]++
+			}
+			run = 0
+		}
+	}
+
+	best := 0
+	for i, n := range runs {
+		if n > runs[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// rowProfile returns the number of black pixels in each row of
+// a binary image
+func rowProfile(img *image.Gray) []float64 {
+	b := img.Bounds()
+	p := make([]float64, b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.GrayAt(x, y).Y == 0 {
+				p[y-b.Min.Y]++
+			}
+		}
+	}
+	return p
+}
+
+// lineHeight finds the typical distance between lines of text in
+// a binary image, by finding the strongest period in the
+// autocorrelation of its row profile. It returns 0 if there is
+// no clear period.
+func lineHeight(img *image.Gray) int {
+	p := rowProfile(img)
+	if len(p) < 8 {
+		return 0
+	}
+
+	var mean float64
+	for _, v := range p {
+		mean += v
+	}
+	mean /= float64(len(p))
+	for i := range p {
+		p[i] -= mean
+	}
+
+	maxlag := len(p) / 2
+	corr := make([]float64, maxlag+1)
+	for lag := 1; lag <= maxlag; lag++ {
+		for i := 0; i+lag < len(p); i++ {
+			corr[lag] += p[i] * p[i+lag]
+		}
+		corr[lag] /= float64(len(p) - lag)
+	}
+
+	ispeak := func(lag int) bool {
+		return corr[lag] > 0 && corr[lag] > corr[lag-1] && corr[lag] >= corr[lag+1]
+	}
+
+	// skip past the central peak of the autocorrelation, as any
+	// lag smaller than a line will correlate strongly
+	start := 1
+	for start < maxlag && corr[start] > 0 {
+		start++
+	}
+
+	best := 0
+	for lag := start; lag < maxlag; lag++ {
+		if ispeak(lag) && (best == 0 || corr[lag] > corr[best]) {
+			best = lag
+		}
+	}
+	if best == 0 {
+		return 0
+	}
+
+	// multiples of the line height also correlate well, and may
+	// happen to correlate best, so prefer the first peak which
+	// correlates nearly as well as the best one
+	for lag := start; lag < best; lag++ {
+		if ispeak(lag) && corr[lag] > corr[best]*0.6 {
+			return lag
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestEstimateSizes(t *testing.T) {
+	cases := []struct {
+		filename  string
+		minheight int
+		maxheight int
+		minstroke int
+		maxstroke int
+	}{
+		{"testdata/pg2.png", 95, 115, 5, 12},
+		{"testdata/0002.png", 19, 25, 1, 4},
+		{"testdata/1727_GREENE_0048.png", 30, 38, 2, 6},
+		{"testdata/1687_SCHWEITZER_0030.png", 80, 95, 4, 10},
+	}
+
+	for _, c := range cases {
+		t.Run(c.filename, func(t *testing.T) {
+			img, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			s := EstimateSizes(img)
+			if s.LineHeight < c.minheight || s.LineHeight > c.maxheight {
+				t.Errorf("Line height %d is outside of the expected range %d - %d\n", s.LineHeight, c.minheight, c.maxheight)
+			}
+			if s.StrokeWidth < c.minstroke || s.StrokeWidth > c.maxstroke {
+				t.Errorf("Stroke width %d is outside of the expected range %d - %d\n", s.StrokeWidth, c.minstroke, c.maxstroke)
+			}
+			if s.Binarize%2 == 0 {
+				t.Errorf("Binarization window size %d is not odd\n", s.Binarize)
+			}
+		})
+	}
+
+	t.Run("blank", func(t *testing.T) {
+		blank := image.NewGray(image.Rect(0, 0, 600, 800))
+		s := EstimateSizes(blank)
+		if s.LineHeight != 0 || s.StrokeWidth != 0 {
+			t.Errorf("Measured text in a blank image: %+v\n", s)
+		}
+		if s.Binarize != 11 || s.Wipe != 5 || s.VWipe != 120 || s.Despeckle != 4 || s.Border != 20 || s.Flatten != 120 {
+			t.Errorf("Blank image did not use default sizes: %+v\n", s)
+		}
+	})
+
+	t.Run("thin strokes", func(t *testing.T) {
+		img := whiteGray(image.Rect(0, 0, 600, 800))
+		for y := 20; y < 780; y += 40 {
+			for x := 20; x < 580; x += 4 {
+				fillRect(img, image.Rect(x, y, x+1, y+20))
+			}
+		}
+		s := EstimateSizes(img)
+		if s.StrokeWidth != 1 {
+			t.Fatalf("Stroke width %d, expected 1\n", s.StrokeWidth)
+		}
+		if s.Despeckle != 1 {
+			t.Errorf("Despeckle size %d for a stroke width of 1, expected 1\n", s.Despeckle)
+		}
+	})
+}
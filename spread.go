@@ -0,0 +1,75 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/draw"
+
+	"rescribe.xyz/integral"
+)
+
+// FindGutter finds the gutter between the two pages of a binarised
+// image of a double page spread. It moves a wsize width vertical
+// slice across the central 40% of the image, and returns the middle
+// of the area with the lowest proportion of black pixels, and
+// whether that proportion is at or below thresh, meaning that a
+// gutter was found. The gutter is an x coordinate within the bounds
+// of img, so for an image which doesn't start at 0 it includes
+// img.Bounds().Min.X, and can be passed straight to SplitSpread.
+//
+// Note that the gap between two columns on a single page may also
+// be found as a gutter, so this should only be used on images which
+// are expected to be spreads.
+func FindGutter(img *image.Gray, wsize int, thresh float64) (int, bool) {
+	b := img.Bounds()
+	intImg := integral.NewImage(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(intImg, intImg.Bounds(), img, b.Min, draw.Src)
+
+	minx := b.Dx() * 3 / 10
+	maxx := b.Dx()*7/10 - wsize
+
+	best := 100.0
+	var bestxs []int
+	for x := minx; x <= maxx; x++ {
+		prop := ProportionSlice(*intImg, x, wsize)
+		if prop < best {
+			bestxs = make([]int, 0)
+			best = prop
+		}
+		if prop == best {
+			bestxs = append(bestxs, x)
+		}
+	}
+	if len(bestxs) == 0 {
+		return b.Min.X + b.Dx()/2, false
+	}
+
+	gutter := findbestedge(*intImg, bestxs[len(bestxs)/2], wsize)
+	return b.Min.X + gutter, best <= thresh
+}
+
+// cropImage returns a copy of the area r of an image, starting at
+// 0, 0. Gray images stay Gray, and anything else becomes RGBA.
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		return Crop(gray, r)
+	}
+	r = r.Intersect(img.Bounds())
+	new := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(new, new.Bounds(), img, r.Min, draw.Src)
+	return new
+}
+
+// SplitSpread splits an image of a double page spread at the
+// gutter, as found by FindGutter, returning copies of the left and
+// right pages. Both returned images start at 0, 0. Gray images
+// stay Gray, and any other type of image is returned as RGBA.
+func SplitSpread(img image.Image, gutter int) (image.Image, image.Image) {
+	b := img.Bounds()
+	left := image.Rect(b.Min.X, b.Min.Y, gutter, b.Max.Y)
+	right := image.Rect(gutter, b.Min.Y, b.Max.X, b.Max.Y)
+	return cropImage(img, left), cropImage(img, right)
+}
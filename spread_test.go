@@ -0,0 +1,68 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestFindGutter(t *testing.T) {
+	left, err := decode("testdata/1727_GREENE_0048.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	right, err := decode("testdata/1687_SCHWEITZER_0030.png")
+	if err != nil {
+		t.Fatalf("Could not open file: %v\n", err)
+	}
+	leftbin, rightbin := Otsu(left), Otsu(right)
+
+	lb, rb := leftbin.Bounds(), rightbin.Bounds()
+	h := lb.Dy()
+	if rb.Dy() > h {
+		h = rb.Dy()
+	}
+	spread := image.NewGray(image.Rect(0, 0, lb.Dx()+rb.Dx(), h))
+	draw.Draw(spread, spread.Bounds(), &image.Uniform{color.Gray{255}}, image.Point{}, draw.Src)
+	draw.Draw(spread, lb, leftbin, image.Point{}, draw.Src)
+	draw.Draw(spread, rb.Add(image.Pt(lb.Dx(), 0)), rightbin, image.Point{}, draw.Src)
+
+	leftarea, _ := WipeEdges(leftbin, 5, 0.05, 30)
+	rightarea, _ := WipeEdges(rightbin, 5, 0.05, 30)
+	minx := leftarea.Max.X
+	maxx := lb.Dx() + rightarea.Min.X
+
+	gutter, ok := FindGutter(spread, 5, 0.05)
+	if !ok {
+		t.Fatalf("No gutter found in spread\n")
+	}
+	if gutter < minx || gutter > maxx {
+		t.Errorf("Gutter %d outside the gap between pages, %d to %d\n", gutter, minx, maxx)
+	}
+
+	offset := image.NewGray(spread.Bounds().Add(image.Pt(100, 50)))
+	copy(offset.Pix, spread.Pix)
+	if g, _ := FindGutter(offset, 5, 0.05); g != gutter+100 {
+		t.Errorf("Gutter %d found in an image starting at 100, 50, expected %d\n", g, gutter+100)
+	}
+
+	l, r := SplitSpread(spread, gutter)
+	if l.Bounds() != image.Rect(0, 0, gutter, h) {
+		t.Errorf("Left page has bounds %v\n", l.Bounds())
+	}
+	if r.Bounds() != image.Rect(0, 0, spread.Bounds().Dx()-gutter, h) {
+		t.Errorf("Right page has bounds %v\n", r.Bounds())
+	}
+	if r.At(0, 0) != spread.At(gutter, 0) {
+		t.Errorf("Right page does not start at the gutter\n")
+	}
+
+	if _, ok := FindGutter(leftbin, 5, 0.05); ok {
+		t.Errorf("Gutter found in a single page\n")
+	}
+}
@@ -6,8 +6,14 @@ package preproc
 
 import (
 	"errors"
+	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"math"
+	"sync"
+
+	"rescribe.xyz/integral"
 )
 
 type SummableImage interface {
@@ -15,75 +21,83 @@ type SummableImage interface {
 	Sum(r image.Rectangle) uint64
 }
 
-func mean(i []int) float64 {
-	sum := 0
-	for _, n := range i {
-		sum += n
+// sumsMeanStdDev returns the mean and standard deviation of n 8
+// bit values, given their sum and the sum of their squares. The
+// standard deviation uses the sample variance, dividing by n-1, as
+// the per-pixel calculation this replaced did.
+func sumsMeanStdDev(n, sum, sqsum uint64) (float64, float64) {
+	m := float64(sum) / float64(n)
+	if n < 2 {
+		return m, 0
 	}
-	return float64(sum) / float64(len(i))
+	// n * the sum of the squared differences from the mean, which is
+	// exact in integers
+	sqdiff := n*sqsum - sum*sum
+	variance := float64(sqdiff) / float64(n) / float64(n-1)
+	return m, math.Sqrt(variance)
 }
 
-func stddev(i []int) float64 {
-	m := mean(i)
-
-	var sum float64
-	for _, n := range i {
-		sum += (float64(n) - m) * (float64(n) - m)
-	}
-	variance := sum / float64(len(i)-1)
-	return math.Sqrt(variance)
+// centeredRectangle returns the window around a point used by the
+// local thresholding algorithms, which runs from size/2+1 pixels
+// before the point to size/2-1 pixels after it in each direction.
+// Windows near the edge of an image are cut off at its bounds.
+func centeredRectangle(x, y, size int) image.Rectangle {
+	step := size / 2
+	return image.Rect(x-step-1, y-step-1, x+step, y+step)
 }
 
-func meanstddev(i []int) (float64, float64) {
-	m := mean(i)
-
-	var sum float64
-	for _, n := range i {
-		sum += (float64(n) - m) * (float64(n) - m)
-	}
-	variance := float64(sum) / float64(len(i)-1)
-	return m, math.Sqrt(variance)
+// integralMeanStdDev returns the mean and standard deviation of
+// the pixels of an image in a window, using its Integral Images.
+// integral.Image holds Gray16 values, which for 8 bit Gray are
+// the value * 257, so the sums are divided by 257 to get back to
+// 8 bit values exactly.
+func integralMeanStdDev(intImg integral.Image, intSqImg integral.SqImage, r image.Rectangle) (float64, float64) {
+	const scale = 257
+	r = r.Intersect(intImg.Bounds())
+	n := uint64(r.Dx() * r.Dy())
+	return sumsMeanStdDev(n, intImg.Sum(r)/scale, intSqImg.Sum(r)/(scale*scale))
 }
 
-// gets the pixel values surrounding a point in the image
-func surrounding(img *image.Gray, x int, y int, size int) []int {
-	b := img.Bounds()
-	step := size / 2
-
-	miny := y - step - 1
-	if miny < b.Min.Y {
-		miny = b.Min.Y
+// inBands splits the rows of r into workers horizontal bands, and
+// runs f on each band in its own goroutine. It returns once they
+// have all finished.
+func inBands(r image.Rectangle, workers int, f func(miny, maxy int)) {
+	if workers < 1 {
+		workers = 1
 	}
-	minx := x - step - 1
-	if minx < b.Min.X {
-		minx = b.Min.X
+	if workers > r.Dy() {
+		workers = r.Dy()
 	}
-	maxy := y + step
-	if maxy > b.Max.Y {
-		maxy = b.Max.Y
-	}
-	maxx := x + step
-	if maxx > b.Max.X {
-		maxx = b.Max.X
+	if workers <= 1 {
+		f(r.Min.Y, r.Max.Y)
+		return
 	}
 
-	var s []int
-	for yi := miny; yi < maxy; yi++ {
-		for xi := minx; xi < maxx; xi++ {
-			s = append(s, int(img.GrayAt(xi, yi).Y))
+	bandh := (r.Dy() + workers - 1) / workers
+	var wg sync.WaitGroup
+	for miny := r.Min.Y; miny < r.Max.Y; miny += bandh {
+		maxy := miny + bandh
+		if maxy > r.Max.Y {
+			maxy = r.Max.Y
 		}
+		wg.Add(1)
+		go func(miny, maxy int) {
+			defer wg.Done()
+			f(miny, maxy)
+		}(miny, maxy)
 	}
-	return s
+	wg.Wait()
 }
 
 // BinToZeroInv converts a binary thresholded image to a zero inverse
-// binary thresholded image
-func BinToZeroInv(bin *image.Gray, orig *image.RGBA) (*image.RGBA, error) {
+// binary thresholded image, in which the background is white and the
+// foreground keeps its original colour. orig can be any type of image.
+func BinToZeroInv(bin *image.Gray, orig image.Image) (*image.RGBA, error) {
 	b := bin.Bounds()
 	if !b.Eq(orig.Bounds()) {
-		return orig, errors.New("bin and orig images need to be the same dimensions")
+		return nil, errors.New("bin and orig images need to be the same dimensions")
 	}
-	newimg := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	newimg := image.NewRGBA(b)
 	for y := b.Min.Y; y < b.Max.Y; y++ {
 		for x := b.Min.X; x < b.Max.X; x++ {
 			if bin.GrayAt(x, y).Y == 255 {
@@ -96,3 +110,102 @@ func BinToZeroInv(bin *image.Gray, orig *image.RGBA) (*image.RGBA, error) {
 
 	return newimg, nil
 }
+
+// BinToZero converts a binary thresholded image to a zero binary
+// thresholded image, in which the foreground is black and the
+// background keeps its original colour.
+func BinToZero(bin *image.Gray, orig image.Image) (*image.RGBA, error) {
+	b := bin.Bounds()
+	if !b.Eq(orig.Bounds()) {
+		return nil, errors.New("bin and orig images need to be the same dimensions")
+	}
+	newimg := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if bin.GrayAt(x, y).Y == 255 {
+				newimg.Set(x, y, orig.At(x, y))
+			} else {
+				newimg.Set(x, y, bin.GrayAt(x, y))
+			}
+		}
+	}
+
+	return newimg, nil
+}
+
+// BinToTrunc converts a binary thresholded image to a truncated
+// thresholded image, in which the foreground keeps its original
+// grey level and the background is set to the lightest grey
+// level found in the foreground. This keeps the shading of the
+// text while flattening the background. If there is no foreground,
+// such as on a blank page, the background is left white.
+func BinToTrunc(bin *image.Gray, orig image.Image) (*image.Gray, error) {
+	b := bin.Bounds()
+	if !b.Eq(orig.Bounds()) {
+		return nil, errors.New("bin and orig images need to be the same dimensions")
+	}
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, orig, b.Min, draw.Src)
+
+	var max uint8
+	var found bool
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if bin.GrayAt(x, y).Y != 0 {
+				continue
+			}
+			found = true
+			if v := gray.GrayAt(x, y).Y; v > max {
+				max = v
+			}
+		}
+	}
+	if !found {
+		max = 255
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if bin.GrayAt(x, y).Y == 255 {
+				gray.SetGray(x, y, color.Gray{max})
+			}
+		}
+	}
+
+	return gray, nil
+}
+
+// BinToBinaryInv converts a binary thresholded image to an inverse
+// binary thresholded image, with a white foreground on a black
+// background.
+func BinToBinaryInv(bin *image.Gray) *image.Gray {
+	b := bin.Bounds()
+	newimg := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			newimg.SetGray(x, y, color.Gray{255 - bin.GrayAt(x, y).Y})
+		}
+	}
+	return newimg
+}
+
+// BinTypes lists the threshold types understood by BinToType.
+var BinTypes = []string{"binary", "binary_inv", "trunc", "tozero", "zeroinv"}
+
+// BinToType converts a binary thresholded image to the threshold
+// type named by binType, which is one of those listed in BinTypes.
+func BinToType(binType string, bin *image.Gray, orig image.Image) (image.Image, error) {
+	switch binType {
+	case "binary":
+		return bin, nil
+	case "binary_inv":
+		return BinToBinaryInv(bin), nil
+	case "trunc":
+		return BinToTrunc(bin, orig)
+	case "tozero":
+		return BinToZero(bin, orig)
+	case "zeroinv":
+		return BinToZeroInv(bin, orig)
+	}
+	return nil, fmt.Errorf("Unknown threshold type %s", binType)
+}
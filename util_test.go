@@ -0,0 +1,92 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"testing"
+)
+
+func TestBinToType(t *testing.T) {
+	r := image.Rect(0, 0, 4, 1)
+	bin := image.NewGray(r)
+	for x, v := range []uint8{0, 255, 0, 255} {
+		bin.SetGray(x, 0, color.Gray{v})
+	}
+
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio444)
+	for x, v := range []uint8{20, 200, 60, 220} {
+		ycbcr.Y[ycbcr.YOffset(x, 0)] = v
+		ycbcr.Cb[ycbcr.COffset(x, 0)] = 128
+		ycbcr.Cr[ycbcr.COffset(x, 0)] = 128
+	}
+	gray := image.NewGray(r)
+	paletted := image.NewPaletted(r, palette.WebSafe)
+	for x, v := range []uint8{20, 200, 60, 220} {
+		gray.SetGray(x, 0, color.Gray{v})
+		paletted.Set(x, 0, color.Gray{v})
+	}
+
+	cases := []struct {
+		name    string
+		bintype string
+		orig    image.Image
+		want    []uint8
+	}{
+		{"binary", "binary", ycbcr, []uint8{0, 255, 0, 255}},
+		{"binary_inv", "binary_inv", ycbcr, []uint8{255, 0, 255, 0}},
+		{"zeroinv_ycbcr", "zeroinv", ycbcr, []uint8{20, 255, 60, 255}},
+		{"zeroinv_gray", "zeroinv", gray, []uint8{20, 255, 60, 255}},
+		{"tozero_gray", "tozero", gray, []uint8{0, 200, 0, 220}},
+		{"trunc_gray", "trunc", gray, []uint8{20, 60, 60, 60}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := BinToType(c.bintype, bin, c.orig)
+			if err != nil {
+				t.Fatalf("Error converting to %s: %v\n", c.bintype, err)
+			}
+			for x, want := range c.want {
+				got := color.GrayModel.Convert(out.At(x, 0)).(color.Gray).Y
+				if got != want {
+					t.Errorf("Pixel %d is %d, not %d\n", x, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("paletted", func(t *testing.T) {
+		_, err := BinToZeroInv(bin, paletted)
+		if err != nil {
+			t.Errorf("Error converting paletted image: %v\n", err)
+		}
+	})
+
+	t.Run("trunc_blank", func(t *testing.T) {
+		blank := image.NewGray(r)
+		for x := 0; x < r.Dx(); x++ {
+			blank.SetGray(x, 0, color.Gray{255})
+		}
+		out, err := BinToTrunc(blank, gray)
+		if err != nil {
+			t.Fatalf("Error converting to trunc: %v\n", err)
+		}
+		for x := 0; x < r.Dx(); x++ {
+			if got := out.GrayAt(x, 0).Y; got != 255 {
+				t.Errorf("Pixel %d of a page with no foreground is %d, not 255\n", x, got)
+			}
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := BinToType("nonexistent", bin, gray)
+		if err == nil {
+			t.Errorf("No error returned for unknown threshold type\n")
+		}
+	})
+}
@@ -4,8 +4,6 @@
 
 package preproc
 
-// TODO: optionally return the edges chosen
-
 import (
 	"errors"
 	"fmt"
@@ -23,6 +21,12 @@ import (
 // vertical slice of an image starting at x, width pixels wide.
 func ProportionSlice(i SummableImage, x int, width int) float64 {
 	r := image.Rect(x, 0, x+width, i.Bounds().Dy())
+	return proportionRect(i, r)
+}
+
+// proportionRect returns the proportion of black pixels in the
+// area r of an image.
+func proportionRect(i SummableImage, r image.Rectangle) float64 {
 	in := r.Intersect(i.Bounds())
 	area := in.Dx() * in.Dy()
 	// 1 << 16 - 1 as we're using Gray16, so 1 << 16 - 1 = white
@@ -170,32 +174,62 @@ func sideways(img image.Image) *image.Gray {
 	return new
 }
 
-// Wipe fills the sections of image which fall outside the content
-// area with white, providing the content area is above min %
-func Wipe(img *image.Gray, wsize int, thresh float64, min int) *image.Gray {
+// WipeEdges finds the left and right edges of the content area of
+// an image, in the same way as Wipe, without changing the image. It
+// returns the content area, and whether it is narrower than min %
+// of the image width, in which case Wipe would leave the image
+// untouched.
+func WipeEdges(img *image.Gray, wsize int, thresh float64, min int) (image.Rectangle, bool) {
 	b := img.Bounds()
 	intImg := integral.NewImage(b)
 	draw.Draw(intImg, b, img, b.Min, draw.Src)
 	lowedge, highedge := findedges(*intImg, wsize, thresh)
-	if toonarrow(img, lowedge, highedge, min) {
-		return img
-	}
-	return wipesides(img, lowedge, highedge)
+	r := image.Rect(lowedge, b.Min.Y, highedge, b.Max.Y)
+	return r, toonarrow(img, lowedge, highedge, min)
 }
 
-// VWipe fills the sections of image which fall outside the vertical
-// content area with white, providing the content area is above min %
-func VWipe(img *image.Gray, wsize int, thresh float64, min int) *image.Gray {
+// VWipeEdges finds the top and bottom edges of the content area of
+// an image, in the same way as VWipe, without changing the image. It
+// returns the content area, and whether its height is less than
+// min % of the image width, in which case VWipe would leave the
+// image untouched. Note that, as with VWipe, min is compared to the
+// width of the image, not its height.
+func VWipeEdges(img *image.Gray, wsize int, thresh float64, min int) (image.Rectangle, bool) {
+	_, r, narrow := vwipeedges(img, wsize, thresh, min)
+	return r, narrow
+}
+
+// vwipeedges implements VWipeEdges, also returning the image flipped
+// sideways, so that VWipe can reuse it
+func vwipeedges(img *image.Gray, wsize int, thresh float64, min int) (*image.Gray, image.Rectangle, bool) {
 	rotimg := sideways(img)
 	b := rotimg.Bounds()
 	intImg := integral.NewImage(b)
 	draw.Draw(intImg, b, rotimg, b.Min, draw.Src)
 	// TODO: test whether there are any places where Outin makes a real difference
 	lowedge, highedge := findedgesOutin(*intImg, wsize, thresh)
-	if toonarrow(img, lowedge, highedge, min) {
+	r := image.Rect(b.Min.Y, lowedge, b.Max.Y, highedge)
+	return rotimg, r, toonarrow(img, lowedge, highedge, min)
+}
+
+// Wipe fills the sections of image which fall outside the content
+// area with white, providing the content area is above min %
+func Wipe(img *image.Gray, wsize int, thresh float64, min int) *image.Gray {
+	r, narrow := WipeEdges(img, wsize, thresh, min)
+	if narrow {
+		return img
+	}
+	return wipesides(img, r.Min.X, r.Max.X)
+}
+
+// VWipe fills the sections of image which fall outside the vertical
+// content area with white, providing the content area is above min %
+func VWipe(img *image.Gray, wsize int, thresh float64, min int) *image.Gray {
+	rotimg, r, narrow := vwipeedges(img, wsize, thresh, min)
+	if narrow {
 		return img
 	}
-	wiped := wipesides(rotimg, lowedge, highedge)
+	wiped := wipesides(rotimg, r.Min.Y, r.Max.Y)
 	return sideways(wiped)
 }
 
@@ -204,35 +238,83 @@ func VWipe(img *image.Gray, wsize int, thresh float64, min int) *image.Gray {
 // content area is above min %.
 // inPath: path of the input image.
 // outPath: path to save the output image.
-// hwsize: window size (width) for horizontal wipe algorithm.
+// hwsize: window size (width) for horizontal wipe algorithm, or 0 to use EstimateSizes.
 // hthresh: threshold for horizontal wipe algorithm.
 // hmin: minimum % of content area width to consider valid.
-// vwsize: window size (height) for vertical wipe algorithm.
+// vwsize: window size (height) for vertical wipe algorithm, or 0 to use EstimateSizes.
 // vthresh: threshold for vertical wipe algorithm.
 // vmin: minimum % of content area height to consider valid.
 func WipeFile(inPath string, outPath string, hwsize int, hthresh float64, hmin int, vwsize int, vthresh float64, vmin int) error {
-	f, err := os.Open(inPath)
+	return WipeFileBorder(inPath, outPath, hwsize, hthresh, hmin, vwsize, vthresh, vmin, false)
+}
+
+// WipeFileBorder wipes an image file like WipeFile, and if border
+// is true first removes any black border around the image, so that
+// it isn't taken as part of the content area. The border is found
+// with RemoveBorder in a copy of the image binarised with Otsu's
+// method, at the thickness recommended by EstimateSizes, and the
+// same area is whitened in the greyscale image.
+func WipeFileBorder(inPath string, outPath string, hwsize int, hthresh float64, hmin int, vwsize int, vthresh float64, vmin int, border bool) error {
+	gray, err := decodeGray(inPath)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Could not open file %s: %v", inPath, err))
+		return err
+	}
+
+	hwsize, vwsize, bsize := wipeSizes(gray, hwsize, vwsize, border)
+	if border {
+		gray = removeBorderGray(gray, bsize)
+	}
+
+	vclean := VWipe(gray, vwsize, vthresh, vmin)
+	clean := Wipe(vclean, hwsize, hthresh, hmin)
+
+	return encodePNG(outPath, clean)
+}
+
+// wipeSizes replaces any wipe window sizes which are 0 with those
+// recommended by EstimateSizes, and if border is set also returns
+// its border thickness for RemoveBorder. The image is only measured
+// if one of these is needed.
+func wipeSizes(img *image.Gray, hwsize int, vwsize int, border bool) (int, int, int) {
+	if hwsize != 0 && vwsize != 0 && !border {
+		return hwsize, vwsize, 0
+	}
+	sizes := EstimateSizes(img)
+	if hwsize == 0 {
+		hwsize = sizes.Wipe
+	}
+	if vwsize == 0 {
+		vwsize = sizes.VWipe
+	}
+	return hwsize, vwsize, sizes.Border
+}
+
+// decodeGray opens and decodes an image file, converting it to
+// grayscale
+func decodeGray(path string) (*image.Gray, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Could not open file %s: %v", path, err))
 	}
 	defer f.Close()
 	img, _, err := image.Decode(f)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Could not decode image: %v", err))
+		return nil, errors.New(fmt.Sprintf("Could not decode image: %v", err))
 	}
 	b := img.Bounds()
 	gray := image.NewGray(b)
 	draw.Draw(gray, b, img, b.Min, draw.Src)
+	return gray, nil
+}
 
-	vclean := VWipe(gray, vwsize, vthresh, vmin)
-	clean := Wipe(vclean, hwsize, hthresh, hmin)
-
-	f, err = os.Create(outPath)
+// encodePNG saves an image to a file as a PNG
+func encodePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Could not create file %s: %v", outPath, err))
+		return errors.New(fmt.Sprintf("Could not create file %s: %v", path, err))
 	}
 	defer f.Close()
-	err = png.Encode(f, clean)
+	err = png.Encode(f, img)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Could not encode image: %v", err))
 	}
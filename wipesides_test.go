@@ -137,3 +137,96 @@ func TestWipeSides(t *testing.T) {
 		})
 	}
 }
+
+func TestWipeEdges(t *testing.T) {
+	cases := []struct {
+		filename string
+		wsize    int
+		thresh   float64
+		vwsize   int
+		vthresh  float64
+	}{
+		{"testdata/1727_GREENE_0048.png", 5, 0.02, 120, 0.005},
+		{"testdata/1687_SCHWEITZER_0030.png", 5, 0.02, 90, 0.005},
+	}
+
+	for _, c := range cases {
+		t.Run(c.filename, func(t *testing.T) {
+			img, err := decode(c.filename)
+			if err != nil {
+				t.Fatalf("Could not open file %s: %v\n", c.filename, err)
+			}
+			orig, _ := decode(c.filename)
+			b := img.Bounds()
+
+			r, narrow := WipeEdges(img, c.wsize, c.thresh, 30)
+			if narrow {
+				t.Fatalf("Content area %v reported as too narrow\n", r)
+			}
+			if r.Min.Y != b.Min.Y || r.Max.Y != b.Max.Y {
+				t.Errorf("Content area %v does not span the image height\n", r)
+			}
+			vr, vnarrow := VWipeEdges(img, c.vwsize, c.vthresh, 30)
+			if vnarrow {
+				t.Fatalf("Content area %v reported as too short\n", vr)
+			}
+			if vr.Min.X != b.Min.X || vr.Max.X != b.Max.X {
+				t.Errorf("Content area %v does not span the image width\n", vr)
+			}
+			if !imgsequal(img, orig) {
+				t.Errorf("Image was modified while finding edges\n")
+			}
+
+			wiped := Wipe(img, c.wsize, c.thresh, 30)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					p := image.Pt(x, y)
+					if !p.In(r) && wiped.GrayAt(x, y).Y != 255 {
+						t.Fatalf("Pixel %v outside content area %v not wiped\n", p, r)
+					}
+					if p.In(r) && wiped.GrayAt(x, y) != img.GrayAt(x, y) {
+						t.Fatalf("Pixel %v inside content area %v was changed\n", p, r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVWipeMin(t *testing.T) {
+	// a tall narrow page, with content which is shorter than half the
+	// page height but taller than the page width
+	b := image.Rect(0, 0, 200, 1000)
+	img := whiteGray(b)
+	for y := 100; y < 400; y += 20 {
+		fillRect(img, image.Rect(20, y, 180, y+10))
+	}
+	content := image.Rect(20, 100, 180, 390)
+
+	cases := []struct {
+		min    int
+		narrow bool
+	}{
+		{50, false},
+		{140, false},
+		{160, true},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%d", c.min), func(t *testing.T) {
+			r, narrow := VWipeEdges(img, 20, 0.005, c.min)
+			if narrow != c.narrow {
+				t.Fatalf("Content area %v reported as too short %v, expected %v, as min is a percentage of the image width\n", r, narrow, c.narrow)
+			}
+			if r.Min.Y > content.Min.Y || r.Max.Y < content.Max.Y {
+				t.Errorf("Content area %v does not cover the content %v\n", r, content)
+			}
+			wiped := VWipe(img, 20, 0.005, c.min)
+			if narrow && !imgsequal(wiped, img) {
+				t.Errorf("Image was wiped even though the content area is too short\n")
+			}
+			if !narrow && blackProportion(wiped, b) != blackProportion(img, b) {
+				t.Errorf("Content was wiped\n")
+			}
+		})
+	}
+}
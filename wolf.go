@@ -0,0 +1,103 @@
+// Copyright 2020 Nick White.
+// Use of this source code is governed by the GPLv3
+// license that can be found in the LICENSE file.
+
+package preproc
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"rescribe.xyz/integral"
+)
+
+func init() {
+	RegisterBinarizer("wolf", func(p BinarizerParams) Binarizer {
+		return WolfBinarizer{K: p.K, Wsize: p.Wsize}
+	})
+}
+
+// WolfBinarizer is a Binarizer which uses the Integral Image
+// implementation of Wolf and Jolion's algorithm.
+type WolfBinarizer struct {
+	K     float64
+	Wsize int
+}
+
+// Binarize binarizes an image with IntegralWolf.
+func (w WolfBinarizer) Binarize(img image.Image) *image.Gray {
+	return IntegralWolf(img, w.K, w.Wsize)
+}
+
+// BinarizeIntegral binarizes an image with PreCalcedWolf.
+func (w WolfBinarizer) BinarizeIntegral(intImg integral.Image, intSqImg integral.SqImage, img image.Image) *image.Gray {
+	return PreCalcedWolf(intImg, intSqImg, img, w.K, w.Wsize)
+}
+
+// DefaultK returns 0.5, which works well for most images.
+func (w WolfBinarizer) DefaultK() float64 {
+	return 0.5
+}
+
+// IntegralWolf implements Wolf and Jolion's algorithm for text
+// binarization using Integral Images, see paper "Extraction and
+// recognition of artificial text in multimedia documents" (2003).
+// It normalises Sauvola's threshold by the contrast of the whole
+// image, which makes it work better on low contrast pages.
+func IntegralWolf(img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+
+	intImg := integral.NewImage(b)
+	draw.Draw(intImg, b, img, b.Min, draw.Src)
+	intSqImg := integral.NewSqImage(b)
+	draw.Draw(intSqImg, b, img, b.Min, draw.Src)
+
+	return PreCalcedWolf(*intImg, *intSqImg, img, ksize, windowsize)
+}
+
+// PreCalcedWolf Implements Wolf and Jolion's algorithm using precalculated Integral Images
+func PreCalcedWolf(intImg integral.Image, intSqImg integral.SqImage, img image.Image, ksize float64, windowsize int) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+	new := image.NewGray(b)
+
+	// The algorithm needs the darkest grey value in the image and
+	// the greatest standard deviation of any window, so find those
+	// first.
+	mingray := uint8(255)
+	var maxdev float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if v := gray.GrayAt(x, y).Y; v < mingray {
+				mingray = v
+			}
+			r := centeredRectangle(x, y, windowsize)
+			_, dev := integralMeanStdDev(intImg, intSqImg, r)
+			if dev > maxdev {
+				maxdev = dev
+			}
+		}
+	}
+	if maxdev == 0 {
+		maxdev = 1
+	}
+	min := float64(mingray)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r := centeredRectangle(x, y, windowsize)
+			m, dev := integralMeanStdDev(intImg, intSqImg, r)
+			threshold := m - ksize*(1-dev/maxdev)*(m-min)
+			if gray.GrayAt(x, y).Y < uint8(math.Round(threshold)) {
+				new.SetGray(x, y, color.Gray{0})
+			} else {
+				new.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+
+	return new
+}